@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/dhruvsaxena1998/rel/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	decompileFileInput   string
+	decompileInlineInput string
+	decompileOutFile     string
+)
+
+var DecompileCommand = &cobra.Command{
+	Use:   "decompile [flags]",
+	Short: "Decompile JSONLogic to REL",
+	// Errors are about the input, not the flags; main prints them once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (decompileFileInput == "" && decompileInlineInput == "") || (decompileFileInput != "" && decompileInlineInput != "") {
+			return fmt.Errorf("you must specify exactly one of --file or --inline")
+		}
+
+		input, err := readInput(decompileFileInput, decompileInlineInput)
+		if err != nil {
+			return err
+		}
+
+		// Convert JSONLogic back to REL source
+		rel, err := api.DecompileJSON([]byte(input))
+		if err != nil {
+			return err
+		}
+
+		// Write output
+		out, closeOutput, err := openOutput(decompileOutFile)
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		if _, err := fmt.Fprintln(out, rel); err != nil {
+			return fmt.Errorf("failed to write output: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	DecompileCommand.Flags().StringVarP(&decompileFileInput, "file", "f", "", "Path to JSONLogic input file")
+	DecompileCommand.Flags().StringVarP(&decompileInlineInput, "inline", "i", "", "Provide inline JSONLogic document")
+	DecompileCommand.Flags().StringVarP(&decompileOutFile, "out", "o", "", "Output file path (defaults to stdout)")
+
+	DecompileCommand.MarkFlagsMutuallyExclusive("file", "inline")
+}
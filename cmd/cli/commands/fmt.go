@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dhruvsaxena1998/rel/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fmtInlineInput string
+	fmtWrite       bool
+	fmtCheck       bool
+)
+
+var FmtCommand = &cobra.Command{
+	Use:   "fmt [flags] [files...]",
+	Short: "Format REL source in canonical style",
+	Long: "Format REL source in canonical style. Formatted output is written to stdout " +
+		"unless --write rewrites the files in place or --check only reports files that need formatting.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (fmtInlineInput == "") {
+			return fmt.Errorf("you must specify either files or --inline")
+		}
+		if fmtWrite && fmtInlineInput != "" {
+			return fmt.Errorf("--write cannot be used with --inline")
+		}
+
+		if fmtInlineInput != "" {
+			return formatSource("<inline>", fmtInlineInput)
+		}
+
+		unformatted := 0
+		for _, file := range args {
+			input, err := readInput(file, "")
+			if err != nil {
+				return err
+			}
+			if err := formatSource(file, input); err != nil {
+				if err != errNeedsFormatting {
+					return err
+				}
+				unformatted++
+			}
+		}
+
+		if unformatted > 0 {
+			return fmt.Errorf("%d file(s) need formatting", unformatted)
+		}
+		return nil
+	},
+}
+
+// errNeedsFormatting is returned by formatSource in --check mode for unformatted input
+var errNeedsFormatting = errors.New("needs formatting")
+
+// formatSource formats one input according to the --write and --check flags
+func formatSource(name, input string) error {
+	formatted, err := api.Format(input)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	switch {
+	case fmtCheck:
+		if formatted != input {
+			fmt.Fprintln(os.Stdout, name)
+			return errNeedsFormatting
+		}
+	case fmtWrite:
+		if formatted != input {
+			// Keep the permissions of the file being rewritten
+			info, err := os.Stat(name)
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %v", name, err)
+			}
+			if err := os.WriteFile(name, []byte(formatted), info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write %s: %v", name, err)
+			}
+		}
+	default:
+		fmt.Fprint(os.Stdout, formatted)
+	}
+	return nil
+}
+
+func init() {
+	FmtCommand.Flags().StringVarP(&fmtInlineInput, "inline", "i", "", "Provide inline REL expression")
+	FmtCommand.Flags().BoolVarP(&fmtWrite, "write", "w", false, "Write the formatted source back to the files")
+	FmtCommand.Flags().BoolVarP(&fmtCheck, "check", "c", false, "List files that are not formatted and exit with an error")
+
+	FmtCommand.MarkFlagsMutuallyExclusive("write", "check")
+}
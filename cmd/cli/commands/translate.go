@@ -2,10 +2,12 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/dhruvsaxena1998/rel/internal/parser"
+	"github.com/dhruvsaxena1998/rel/pkg/api"
 	"github.com/spf13/cobra"
 )
 
@@ -14,65 +16,76 @@ var (
 	inlineInput string
 	outFile     string
 	prettyPrint bool
+	format      string
 )
 
-func inputAsString() (string, error) {
-	var input string
+const (
+	formatText            = "text"
+	formatJSONDiagnostics = "json-diagnostics"
+)
 
-	if inlineInput != "" {
-		input = inlineInput
-	} else {
-		data, err := os.ReadFile(fileInput)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file %s: %w", fileInput, err)
-		}
-		input = string(data)
+// readInput returns the inline input if given, otherwise the contents of file
+func readInput(file, inline string) (string, error) {
+	if inline != "" {
+		return inline, nil
 	}
 
-	return input, nil
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	return string(data), nil
+}
+
+// openOutput returns the file to write results to, defaulting to stdout. The
+// returned function closes the file.
+func openOutput(path string) (*os.File, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %v", err)
+	}
+	return out, func() { out.Close() }, nil
 }
 
 var TranslateCommand = &cobra.Command{
 	Use:   "translate [flags]",
 	Short: "Translate REL to JSONLogic",
+	// Errors are about the input, not the flags; main prints them once
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if (fileInput == "" && inlineInput == "") || (fileInput != "" && inlineInput != "") {
 			return fmt.Errorf("you must specify exactly one of --file or --inline")
 		}
+		if format != formatText && format != formatJSONDiagnostics {
+			return fmt.Errorf("unknown --format %q, expected %s or %s", format, formatText, formatJSONDiagnostics)
+		}
 
-		input, err := inputAsString()
+		input, err := readInput(fileInput, inlineInput)
 		if err != nil {
 			return err
 		}
 
-		// Create lexer and parser
-		lexer := parser.NewLexer(input)
-		p := parser.NewParser(lexer)
-
-		// Parse expression
-		expression := p.ParseExpression()
-		if expression == nil {
-			return fmt.Errorf("parsing error: %v", p.Errors())
-		}
-
-		// Transform to JSONLogic
-		jsonLogic, err := parser.Transform(expression)
+		// Parse the whole input and compile it to JSONLogic
+		jsonLogic, err := api.ToJSONLogic(input)
 		if err != nil {
-			return fmt.Errorf("transform error: %v", err)
+			var parseErr *api.ParseError
+			if errors.As(err, &parseErr) {
+				return reportDiagnostics(parseErr.Diagnostics)
+			}
+			return err
 		}
 
 		// Write output
-		var out *os.File
-		if outFile != "" {
-			var err error
-			out, err = os.Create(outFile)
-			if err != nil {
-				return fmt.Errorf("failed to create output file: %v", err)
-			}
-			defer out.Close()
-		} else {
-			out = os.Stdout
+		out, closeOutput, err := openOutput(outFile)
+		if err != nil {
+			return err
 		}
+		defer closeOutput()
 
 		// Create encoder and disable HTML escaping
 		enc := json.NewEncoder(out)
@@ -95,6 +108,36 @@ func init() {
 	TranslateCommand.Flags().StringVarP(&inlineInput, "inline", "i", "", "Provide inline REL expression")
 	TranslateCommand.Flags().StringVarP(&outFile, "out", "o", "", "Output file path (defaults to stdout)")
 	TranslateCommand.Flags().BoolVarP(&prettyPrint, "pretty", "p", false, "Pretty-print JSON output")
+	TranslateCommand.Flags().StringVar(&format, "format", formatText, "Error output format: text or json-diagnostics")
 
 	TranslateCommand.MarkFlagsMutuallyExclusive("file", "inline")
 }
+
+// reportDiagnostics turns parser diagnostics into the command error. With
+// --format=json-diagnostics the full list is also written as a JSON array to
+// the output selected by --out, in place of the JSONLogic.
+func reportDiagnostics(diagnostics []api.Diagnostic) error {
+	if format == formatJSONDiagnostics {
+		out, closeOutput, err := openOutput(outFile)
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		if prettyPrint {
+			enc.SetIndent("", "  ")
+		}
+		if err := enc.Encode(diagnostics); err != nil {
+			return fmt.Errorf("JSON encoding error: %v", err)
+		}
+		return fmt.Errorf("parsing failed with %d error(s)", len(diagnostics))
+	}
+
+	messages := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		messages[i] = d.String()
+	}
+	return fmt.Errorf("parsing error:\n%s", strings.Join(messages, "\n"))
+}
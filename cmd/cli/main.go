@@ -20,6 +20,8 @@ var RootCommand = &cobra.Command{
 
 func init() {
 	RootCommand.AddCommand(commands.TranslateCommand)
+	RootCommand.AddCommand(commands.DecompileCommand)
+	RootCommand.AddCommand(commands.FmtCommand)
 }
 
 func main() {
@@ -2,10 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
-	"github.com/dhruvsaxena1998/rel/internal/parser"
+	"github.com/dhruvsaxena1998/rel/pkg/api"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -18,12 +19,24 @@ type TranslateResponse struct {
 	JSONLogic interface{} `json:"jsonLogic"`
 }
 
+type DecompileRequest struct {
+	JSONLogic json.RawMessage `json:"jsonLogic"`
+}
+
+type DecompileResponse struct {
+	Expression string `json:"expression"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// translateHandler compiles a REL expression. An expression that does not
+// parse is answered with 400 and the JSON array of its diagnostics; other
+// failures are answered with an ErrorResponse.
 func translateHandler(w http.ResponseWriter, r *http.Request) {
 	var req TranslateRequest
+	w.Header().Set("Content-Type", "application/json")
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -32,29 +45,46 @@ func translateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create lexer and parser
-	lexer := parser.NewLexer(req.Expression)
-	p := parser.NewParser(lexer)
+	// Parse the whole input and compile it to JSONLogic
+	jsonLogic, err := api.ToJSONLogic(req.Expression)
+	if err != nil {
+		var parseErr *api.ParseError
+		if errors.As(err, &parseErr) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(parseErr.Diagnostics)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transform error: " + err.Error()})
+		return
+	}
 
-	// Parse expression
-	expression := p.ParseExpression()
-	if expression == nil {
+	// Send response
+	json.NewEncoder(w).Encode(TranslateResponse{JSONLogic: jsonLogic})
+}
+
+func decompileHandler(w http.ResponseWriter, r *http.Request) {
+	var req DecompileRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.JSONLogic) == 0 {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid expression: " + p.Errors()[0]})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request format"})
 		return
 	}
 
-	// Transform to JSONLogic
-	jsonLogic, err := parser.Transform(expression)
+	// Convert JSONLogic back to REL source
+	expression, err := api.DecompileJSON(req.JSONLogic)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transform error: " + err.Error()})
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Decompile error: " + err.Error()})
 		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(TranslateResponse{JSONLogic: jsonLogic})
+	json.NewEncoder(w).Encode(DecompileResponse{Expression: expression})
 }
 
 func main() {
@@ -66,6 +96,7 @@ func main() {
 
 	// Routes
 	r.Post("/translate", translateHandler)
+	r.Post("/decompile", decompileHandler)
 
 	// Start server
 	log.Println("Server starting on :8080")
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhruvsaxena1998/rel/pkg/api"
+)
+
+func TestTranslateHandler(t *testing.T) {
+	tests := []struct {
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{`{"expression": "@age == 18"}`, http.StatusOK, `{"jsonLogic":{"==":[{"var":"age"},18]}}`},
+		{`{"expression": `, http.StatusBadRequest, `{"error":"Invalid request format"}`},
+	}
+
+	for i, tt := range tests {
+		rec := httptest.NewRecorder()
+		translateHandler(rec, httptest.NewRequest(http.MethodPost, "/translate", strings.NewReader(tt.body)))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("test[%d] - wrong status. got=%d, want=%d", i, rec.Code, tt.expectedStatus)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.expectedBody {
+			t.Errorf("test[%d] - wrong body. got=%s, want=%s", i, got, tt.expectedBody)
+		}
+	}
+}
+
+func TestTranslateHandlerDiagnostics(t *testing.T) {
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"expression": "(@a > ) AND @b == ]"}`)
+	translateHandler(rec, httptest.NewRequest(http.MethodPost, "/translate", body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong status. got=%d, want=%d", rec.Code, http.StatusBadRequest)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("wrong Content-Type. got=%q", got)
+	}
+
+	// The body is the diagnostics array itself
+	var diagnostics []api.Diagnostic
+	if err := json.Unmarshal(rec.Body.Bytes(), &diagnostics); err != nil {
+		t.Fatalf("body is not a diagnostics array: %v\n%s", err, rec.Body.String())
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %s", len(diagnostics), rec.Body.String())
+	}
+	if d := diagnostics[0]; d.Code != "E_UNEXPECTED_TOKEN" || d.Span.Start.Column != 7 {
+		t.Errorf("wrong first diagnostic: %+v", d)
+	}
+}
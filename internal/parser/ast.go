@@ -2,6 +2,7 @@ package parser
 
 type Node interface {
 	TokenLiteral() string
+	Location() Span // the source range covered by the node
 }
 
 type Expression interface {
@@ -9,23 +10,32 @@ type Expression interface {
 	expressionNode()
 }
 
-// Represents a variable reference like @age
+// Represents a variable reference like @age or @user.address.city, or a
+// reference to a name brought into scope by a quantifier or lambda like
+// item.price
 type Variable struct {
-	Token Token // The '@' token
+	Token Token // The '@' token, or the scoped name
 	Name  string
+	Path  []string // The keys and indices leading to the value, e.g. [items 0 price]
+	Scope string   // The scoped name the path starts from, e.g. item; empty for @variables
+	Key   string   // The first key of Path when it stands for Scope, e.g. current in REDUCE
+	Span  Span
 }
 
 func (v *Variable) expressionNode()      {}
 func (v *Variable) TokenLiteral() string { return v.Token.Literal }
+func (v *Variable) Location() Span       { return v.Span }
 
 // Represents literals like numbers and strings
 type Literal struct {
 	Token Token
 	Value interface{}
+	Span  Span
 }
 
 func (l *Literal) expressionNode()      {}
 func (l *Literal) TokenLiteral() string { return l.Token.Literal }
+func (l *Literal) Location() Span       { return l.Span }
 
 // Represents binary operations like AND, OR, ==, >, <
 type BinaryExpression struct {
@@ -33,36 +43,265 @@ type BinaryExpression struct {
 	Left     Expression
 	Operator string
 	Right    Expression
+	Span     Span
 }
 
 func (be *BinaryExpression) expressionNode()      {}
 func (be *BinaryExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BinaryExpression) Location() Span       { return be.Span }
 
 // Represents unary operations like NOT
 type UnaryExpression struct {
 	Token    Token // The operator token, e.g. NOT
 	Operator string
 	Right    Expression
+	Span     Span
 }
 
 func (ue *UnaryExpression) expressionNode()      {}
 func (ue *UnaryExpression) TokenLiteral() string { return ue.Token.Literal }
+func (ue *UnaryExpression) Location() Span       { return ue.Span }
 
 // Represents array/list literals for IN operator
 type ArrayLiteral struct {
 	Token    Token // The '[' token
 	Elements []Expression
+	Span     Span
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Location() Span       { return al.Span }
 
-// Represents function calls like LOG()
+// Represents function calls like LOG(), and registered infix operators like
+// @location GEO_WITHIN @region, which are calls with two arguments
 type FunctionCall struct {
-	Token     Token // The function name token
-	Function  string
-	Arguments []Expression
+	Token      Token // The function name token
+	Function   string
+	Arguments  []Expression
+	Infix      bool      // Written as left NAME right
+	Implicit   bool      // Inserted by the parser, like the DATE() around @createdAt in @createdAt > NOW()
+	Definition *Function // The function the parser resolved the name to
+	Span       Span
 }
 
 func (fc *FunctionCall) expressionNode()      {}
 func (fc *FunctionCall) TokenLiteral() string { return fc.Token.Literal }
+func (fc *FunctionCall) Location() Span       { return fc.Span }
+
+// Represents range checks like @age BETWEEN 18 AND 65. The bounds are
+// inclusive unless the EXCLUSIVE keyword follows them.
+type BetweenExpression struct {
+	Token     Token // The BETWEEN token
+	Value     Expression
+	Lower     Expression
+	Upper     Expression
+	Exclusive bool
+	Span      Span
+}
+
+func (be *BetweenExpression) expressionNode()      {}
+func (be *BetweenExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BetweenExpression) Location() Span       { return be.Span }
+
+// Represents a variable with fallbacks, written @nickname ?? @name ?? 'guest'
+// or DEFAULT(@nickname, @name, 'guest'). Every operand but the last is a
+// variable; the first one present gives the value.
+type DefaultExpression struct {
+	Token    Token // The first '??' token, or the DEFAULT keyword
+	Operands []Expression
+	Span     Span
+}
+
+func (de *DefaultExpression) expressionNode()      {}
+func (de *DefaultExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DefaultExpression) Location() Span       { return de.Span }
+
+// Represents presence checks, which compile to JSONLogic's missing and
+// missing_some: @email IS MISSING, @email IS NOT MISSING, EXISTS @email and
+// MISSING_SOME(1, [@email, @phone])
+type MissingExpression struct {
+	Token     Token // The IS, EXISTS or MISSING_SOME token
+	Variables []*Variable
+	Need      Expression // How many variables must be present, for MISSING_SOME
+	Negated   bool       // True for EXISTS and IS NOT MISSING
+	Span      Span
+}
+
+func (me *MissingExpression) expressionNode()      {}
+func (me *MissingExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MissingExpression) Location() Span       { return me.Span }
+
+// Represents conditional values: IF cond THEN a ELSE b, and
+// CASE WHEN cond THEN a WHEN cond THEN b ELSE c END. The first branch whose
+// condition is truthy gives the value; without one the ELSE value is used,
+// or null when there is no ELSE.
+type ConditionalExpression struct {
+	Token    Token // The IF or CASE token
+	Branches []ConditionalBranch
+	Else     Expression
+	Span     Span
+}
+
+// ConditionalBranch is one THEN branch of a conditional
+type ConditionalBranch struct {
+	Condition Expression
+	Result    Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) Location() Span       { return ce.Span }
+
+// Represents array quantifiers: ANY @cart AS item: item.price > 100, and the
+// ALL and NONE forms. The body is evaluated once per element of the
+// collection and can only refer to that element, through the name given
+// after AS. Like JSONLogic's all, ALL is false for an empty collection.
+type QuantifierExpression struct {
+	Token      Token // The ANY, ALL or NONE token
+	Collection Expression
+	Element    string
+	Body       Expression
+	Span       Span
+}
+
+func (qe *QuantifierExpression) expressionNode()      {}
+func (qe *QuantifierExpression) TokenLiteral() string { return qe.Token.Literal }
+func (qe *QuantifierExpression) Location() Span       { return qe.Span }
+
+// Represents the collection operations FILTER(@orders, o -> o.paid),
+// MAP(@orders, o -> o.total) and REDUCE(@orders, (sum, o) -> sum + o.total, 0).
+// The lambda body is evaluated once per element and can only refer to the
+// lambda's parameters: the element, preceded for REDUCE by the accumulator.
+type CollectionExpression struct {
+	Token      Token // The FILTER, MAP or REDUCE token
+	Collection Expression
+	Params     []string
+	Body       Expression
+	Initial    Expression // The starting value of the accumulator, for REDUCE
+	Span       Span
+}
+
+func (ce *CollectionExpression) expressionNode()      {}
+func (ce *CollectionExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CollectionExpression) Location() Span       { return ce.Span }
+
+// Represents input that could not be parsed; it stands in for the broken
+// sub-expression so the rest of the tree can still be built
+type BadExpression struct {
+	Token Token // The first token of the invalid input
+	Span  Span
+}
+
+func (bd *BadExpression) expressionNode()      {}
+func (bd *BadExpression) TokenLiteral() string { return bd.Token.Literal }
+func (bd *BadExpression) Location() Span       { return bd.Span }
+
+// newBinaryExpression builds a binary node spanning both operands
+func newBinaryExpression(token Token, left, right Expression) *BinaryExpression {
+	return &BinaryExpression{
+		Token:    token,
+		Left:     left,
+		Operator: token.Literal,
+		Right:    right,
+		Span:     Span{Start: spanOf(left, token).Start, End: spanOf(right, token).End},
+	}
+}
+
+// newUnaryExpression builds a unary node spanning the operator and its operand
+func newUnaryExpression(token Token, right Expression) *UnaryExpression {
+	return &UnaryExpression{
+		Token:    token,
+		Operator: token.Literal,
+		Right:    right,
+		Span:     Span{Start: token.Start, End: spanOf(right, token).End},
+	}
+}
+
+// newVariable builds a variable node from its token
+func newVariable(token Token) *Variable {
+	return &Variable{Token: token, Name: token.Literal, Path: variablePath(token.Literal), Span: token.Span()}
+}
+
+// newElementVariable builds a variable node from a reference to a scoped
+// name like item.price. The data of the body holds the value under key, or
+// is the value itself when key is empty.
+func newElementVariable(token Token, key string) *Variable {
+	path := variablePath(token.Literal)
+	v := &Variable{Token: token, Name: token.Literal, Path: path[1:], Scope: path[0], Key: key, Span: token.Span()}
+	if key != "" {
+		v.Path = append([]string{key}, v.Path...)
+	}
+	return v
+}
+
+// newLiteral builds a literal node from its token and decoded value
+func newLiteral(token Token, value interface{}) *Literal {
+	return &Literal{Token: token, Value: value, Span: token.Span()}
+}
+
+// newFunctionCall builds a call node for the function named by token
+func newFunctionCall(token Token, args []Expression) *FunctionCall {
+	return &FunctionCall{Token: token, Function: token.Literal, Arguments: args, Span: token.Span()}
+}
+
+// stringLiteral returns the value of a string literal
+func stringLiteral(exp Expression) (string, bool) {
+	lit, ok := exp.(*Literal)
+	if !ok || lit.Token.Type != STRING {
+		return "", false
+	}
+	value, ok := lit.Value.(string)
+	return value, ok
+}
+
+// spanOf returns the span of an expression, falling back to the given token
+// when the expression failed to parse
+func spanOf(exp Expression, fallback Token) Span {
+	if exp == nil {
+		return fallback.Span()
+	}
+	return exp.Location()
+}
+
+// inspect calls visit for node and, while visit returns true, for each of
+// its sub-expressions in source order
+func inspect(node Expression, visit func(Expression) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	var children []Expression
+	switch n := node.(type) {
+	case *BinaryExpression:
+		children = []Expression{n.Left, n.Right}
+	case *UnaryExpression:
+		children = []Expression{n.Right}
+	case *ArrayLiteral:
+		children = n.Elements
+	case *FunctionCall:
+		children = n.Arguments
+	case *BetweenExpression:
+		children = []Expression{n.Value, n.Lower, n.Upper}
+	case *DefaultExpression:
+		children = n.Operands
+	case *MissingExpression:
+		children = []Expression{n.Need}
+		for _, v := range n.Variables {
+			children = append(children, v)
+		}
+	case *ConditionalExpression:
+		for _, branch := range n.Branches {
+			children = append(children, branch.Condition, branch.Result)
+		}
+		children = append(children, n.Else)
+	case *QuantifierExpression:
+		children = []Expression{n.Collection, n.Body}
+	case *CollectionExpression:
+		children = []Expression{n.Collection, n.Body, n.Initial}
+	}
+
+	for _, child := range children {
+		inspect(child, visit)
+	}
+}
@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dates are represented as milliseconds since the Unix epoch, so the standard
+// JSONLogic comparison and arithmetic operations work on them unchanged. Two
+// custom operations, which the JSONLogic engine running the rules must
+// provide, produce them:
+//
+//	{"date": [value]}  an ISO 8601 date or date-time string, or a number of
+//	                   epoch milliseconds, as epoch milliseconds; null when
+//	                   the value is not a date
+//	{"now": []}        the current time as epoch milliseconds
+//
+// Durations such as 30d or 1h30m are written into the JSONLogic as plain
+// numbers of milliseconds, so NOW() - 30d is {"-": [{"now": []}, 2592000000]}.
+//
+// When a value of unknown type is compared with a date, or a duration is
+// added to or subtracted from it, the parser wraps it in an implicit DATE(),
+// so @createdAt > NOW() - 30d compares dates even when createdAt holds a
+// string.
+
+// dateLayouts are the formats accepted by DATE(). Times without a zone are UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// durationUnits are the units of a duration literal, in milliseconds. Months
+// and years are left out because their length varies.
+var durationUnits = map[string]float64{
+	"ms": 1,
+	"s":  1000,
+	"m":  60 * 1000,
+	"h":  60 * 60 * 1000,
+	"d":  24 * 60 * 60 * 1000,
+	"w":  7 * 24 * 60 * 60 * 1000,
+}
+
+// epochMillis converts t into the representation used for dates
+func epochMillis(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// parseDate parses a date string in one of the dateLayouts
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or an ISO 8601 date-time", s)
+}
+
+// checkDate reports whether a literal passed to DATE() is a valid date
+func checkDate(s string) error {
+	_, err := parseDate(s)
+	return err
+}
+
+// toDate implements the "date" operation: dates become epoch milliseconds and
+// anything that is not a date becomes nil
+func toDate(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return epochMillis(val)
+	case string:
+		if t, err := parseDate(val); err == nil {
+			return epochMillis(t)
+		}
+		if n, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return n
+		}
+		return nil
+	}
+	if n, ok := asNumber(v); ok {
+		return n
+	}
+	return nil
+}
+
+// parseDuration converts a duration literal such as 30d, 1.5h or 1h30m into milliseconds
+func parseDuration(literal string) (float64, error) {
+	total := 0.0
+	for rest := literal; rest != ""; {
+		i := 0
+		for i < len(rest) && (isDigit(rune(rest[i])) || rest[i] == '.' || rest[i] == '_') {
+			i++
+		}
+		j := i
+		for j < len(rest) && isLetter(rune(rest[j])) {
+			j++
+		}
+
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(rest[:i], "_", ""), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", literal)
+		}
+		unit, ok := durationUnits[rest[i:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %s: unit must be one of ms, s, m, h, d or w", literal)
+		}
+
+		total += amount * unit
+		rest = rest[j:]
+	}
+	return total, nil
+}
+
+// parseDurationLiteral handles duration literals like 30d
+func (p *Parser) parseDurationLiteral() Expression {
+	lit := &Literal{Token: p.currentToken, Span: p.currentToken.Span()}
+	ms, err := parseDuration(p.currentToken.Literal)
+	if err != nil {
+		p.addErrorAt(p.currentToken, E_INVALID_DURATION, err.Error())
+	}
+	lit.Value = ms
+	p.nextToken()
+	return lit
+}
+
+// isDate reports whether exp is known to produce a date: a call to DATE()
+// or NOW(), or a date plus or minus a duration
+func isDate(exp Expression) bool {
+	switch n := exp.(type) {
+	case *FunctionCall:
+		return !n.Infix && (n.Function == "DATE" || n.Function == "NOW")
+	case *BinaryExpression:
+		switch n.Token.Type {
+		case PLUS:
+			return isDate(n.Left) != isDate(n.Right)
+		case MINUS:
+			// The difference of two dates is a duration
+			return isDate(n.Left) && !isDate(n.Right)
+		}
+	}
+	return false
+}
+
+// isDuration reports whether exp is a duration literal
+func isDuration(exp Expression) bool {
+	lit, ok := exp.(*Literal)
+	return ok && lit.Token.Type == DURATION
+}
+
+// coerceDates wraps the operands of a comparison or of date arithmetic that
+// may hold dates in other forms, e.g. strings, in an implicit DATE(). Date
+// arithmetic is + or - with a date or a duration on either side.
+func (p *Parser) coerceDates(operator TokenType, operands []Expression) {
+	arithmetic := operator == PLUS || operator == MINUS
+	temporal := false
+	for _, operand := range operands {
+		if isDate(operand) || (arithmetic && isDuration(operand)) {
+			temporal = true
+		}
+	}
+	if !temporal {
+		return
+	}
+
+	fn, ok := p.registry.Function("DATE")
+	if !ok {
+		return
+	}
+	for i, operand := range operands {
+		if _, bad := operand.(*BadExpression); operand == nil || bad || isDate(operand) {
+			continue
+		}
+		if t := staticType(operand); t != TYPE_ANY && t != TYPE_STRING {
+			continue
+		}
+
+		span := operand.Location()
+		token := NewToken(IDENTIFIER, "DATE")
+		token.Start, token.End = span.Start, span.End
+		fc := &FunctionCall{
+			Token:      token,
+			Function:   fn.Name,
+			Arguments:  []Expression{operand},
+			Implicit:   true,
+			Definition: fn,
+			Span:       span,
+		}
+		p.checkArguments(fc, fn)
+		operands[i] = fc
+	}
+}
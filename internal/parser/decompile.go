@@ -0,0 +1,544 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decompile converts a JSONLogic value, as produced by encoding/json, back
+// into a REL AST. It is the inverse of Transform: every operator Transform
+// emits is supported, and anything else is reported as an error. Use Print to
+// render the result as REL source.
+func Decompile(logic interface{}) (Expression, error) {
+	expression, err := decompile(logic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only paths relative to a quantifier element may be empty or start with
+	// something other than a name; a REL @variable may not
+	for _, v := range freeVariables(expression) {
+		if len(v.Path) == 0 || !isPathName(v.Path[0]) {
+			return nil, fmt.Errorf("var: %q is not a valid REL variable name", strings.Join(v.Path, "."))
+		}
+	}
+	return expression, nil
+}
+
+// decompile converts a JSONLogic value into an AST
+func decompile(logic interface{}) (Expression, error) {
+	switch v := logic.(type) {
+	case nil:
+		return newLiteral(NewToken(NULL, "null"), nil), nil
+	case bool:
+		if v {
+			return newLiteral(NewToken(TRUE, "true"), true), nil
+		}
+		return newLiteral(NewToken(FALSE, "false"), false), nil
+	case string:
+		return newLiteral(NewToken(STRING, quoteString(v)), v), nil
+	case float64:
+		return decompileNumber(strconv.FormatFloat(v, 'f', -1, 64))
+	case json.Number:
+		return decompileNumber(v.String())
+	case []interface{}:
+		return decompileArray(v)
+	case map[string]interface{}:
+		return decompileOperation(v)
+	default:
+		return nil, fmt.Errorf("unsupported JSONLogic value of type %T", v)
+	}
+}
+
+// decompileOperation converts a single-key JSONLogic operation object
+func decompileOperation(op map[string]interface{}) (Expression, error) {
+	if len(op) != 1 {
+		keys := make([]string, 0, len(op))
+		for k := range op {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("JSONLogic operation must have exactly one key, got %v", keys)
+	}
+
+	var operator string
+	var rawArgs interface{}
+	for k, v := range op {
+		operator, rawArgs = k, v
+	}
+
+	switch operator {
+	case "var":
+		return decompileVariable(rawArgs)
+	case "missing":
+		return decompileMissing(rawArgs)
+	case "missing_some":
+		return decompileMissingSome(rawArgs)
+	}
+
+	args, err := decompileArgs(rawArgs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operator, err)
+	}
+
+	switch operator {
+	case "and":
+		return decompileChain(NewToken(AND, "AND"), operator, args)
+	case "or":
+		return decompileChain(NewToken(OR, "OR"), operator, args)
+	case "<", "<=":
+		if len(args) == 3 {
+			return decompileBetween(operator == "<", args), nil
+		}
+		return decompileBinary(NewToken(TokenType(operator), operator), args)
+	case "==":
+		if affix, ok := decompileAffix(args); ok {
+			return affix, nil
+		}
+		return decompileBinary(NewToken(EQ, operator), args)
+	case "!=", "===", "!==", ">", ">=":
+		return decompileBinary(NewToken(TokenType(operator), operator), args)
+	case "+", "*":
+		if len(args) == 1 {
+			return decompileCast(operator, args[0]), nil
+		}
+		if operator == "+" && len(args) > 2 {
+			return newFunctionCall(NewToken(IDENTIFIER, "SUM"), args), nil
+		}
+		return decompileChain(NewToken(TokenType(operator), operator), operator, args)
+	case "-":
+		if len(args) == 1 {
+			return newUnaryExpression(NewToken(MINUS, "-"), args[0]), nil
+		}
+		return decompileBinary(NewToken(MINUS, "-"), args)
+	case "/", "%":
+		return decompileBinary(NewToken(TokenType(operator), operator), args)
+	case "in":
+		return decompileIn(args)
+	case "!":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("!: expected 1 argument, got %d", len(args))
+		}
+		if me, ok := args[0].(*MissingExpression); ok && me.Need == nil && !me.Negated && len(me.Variables) == 1 {
+			return &MissingExpression{Token: NewToken(EXISTS, "EXISTS"), Variables: me.Variables, Negated: true}, nil
+		}
+		return newUnaryExpression(NewToken(BANG, "NOT"), args[0]), nil
+	case "max", "min", "merge", "cat", "substr":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s: expected at least 1 argument", operator)
+		}
+		return newFunctionCall(NewToken(IDENTIFIER, strings.ToUpper(operator)), args), nil
+	case "date":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("date: expected 1 argument, got %d", len(args))
+		}
+		return newFunctionCall(NewToken(IDENTIFIER, "DATE"), args), nil
+	case "now":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("now: expected no arguments, got %d", len(args))
+		}
+		return newFunctionCall(NewToken(IDENTIFIER, "NOW"), args), nil
+	case "matches", "like":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s: expected 2 arguments, got %d", operator, len(args))
+		}
+		fc := newFunctionCall(NewToken(IDENTIFIER, strings.ToUpper(operator)), args)
+		fc.Infix = true
+		return fc, nil
+	case "if":
+		return decompileIf(args)
+	case "some", "all", "none":
+		return decompileQuantifier(operator, args)
+	case "filter", "map":
+		return decompileCollection(operator, args)
+	case "reduce":
+		return decompileReduce(args)
+	case "log":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("log: expected 1 argument, got %d", len(args))
+		}
+		return newFunctionCall(NewToken(LOG, "LOG"), args), nil
+	default:
+		return nil, fmt.Errorf("unsupported JSONLogic operator %q", operator)
+	}
+}
+
+// decompileArgs converts operator arguments. JSONLogic allows a single
+// argument to be given without the surrounding array.
+func decompileArgs(raw interface{}) ([]Expression, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		list = []interface{}{raw}
+	}
+
+	args := make([]Expression, len(list))
+	for i, item := range list {
+		arg, err := decompile(item)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// decompileVariable converts {"var": "name"} and {"var": ["name", default]}
+func decompileVariable(raw interface{}) (Expression, error) {
+	name, ok := raw.(string)
+	if list, isList := raw.([]interface{}); isList && len(list) == 2 {
+		return decompileDefault(list)
+	} else if isList && len(list) == 1 {
+		name, ok = list[0].(string)
+	}
+	if !ok {
+		return nil, fmt.Errorf("var: unsupported argument %v", raw)
+	}
+	// An empty name is the data itself, which inside a quantifier is the element
+	var path []string
+	if name != "" {
+		path = strings.Split(name, ".")
+	}
+	if !isVariablePath(path) {
+		return nil, fmt.Errorf("var: %q is not a valid REL variable name", name)
+	}
+	return &Variable{Token: NewToken(VARIABLE, "@"+name), Name: "@" + name, Path: path}, nil
+}
+
+// decompileDefault converts {"var": ["name", default]} to @name ?? default
+func decompileDefault(list []interface{}) (Expression, error) {
+	variable, err := decompileVariable(list[0])
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := decompile(list[1])
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultExpression{
+		Token:    NewToken(COALESCE, "??"),
+		Operands: []Expression{variable, fallback},
+	}, nil
+}
+
+// decompileMissing converts {"missing": ["name"]} to @name IS MISSING. Several
+// names are equivalent to requiring all of them with MISSING_SOME.
+func decompileMissing(raw interface{}) (Expression, error) {
+	variables, err := decompileVariableList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("missing: %w", err)
+	}
+	if len(variables) == 1 {
+		return &MissingExpression{Token: NewToken(IS, "IS"), Variables: variables}, nil
+	}
+
+	need := newLiteral(NewToken(NUMBER, strconv.Itoa(len(variables))), int64(len(variables)))
+	return &MissingExpression{Token: NewToken(MISSING_SOME, "MISSING_SOME"), Variables: variables, Need: need}, nil
+}
+
+// decompileMissingSome converts {"missing_some": [need, ["a", "b"]]}
+func decompileMissingSome(raw interface{}) (Expression, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) != 2 {
+		return nil, fmt.Errorf("missing_some: expected 2 arguments")
+	}
+	need, err := decompile(list[0])
+	if err != nil {
+		return nil, fmt.Errorf("missing_some: %w", err)
+	}
+	variables, err := decompileVariableList(list[1])
+	if err != nil {
+		return nil, fmt.Errorf("missing_some: %w", err)
+	}
+	return &MissingExpression{Token: NewToken(MISSING_SOME, "MISSING_SOME"), Variables: variables, Need: need}, nil
+}
+
+// decompileVariableList converts the variable names given to missing and missing_some
+func decompileVariableList(raw interface{}) ([]*Variable, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		list = []interface{}{raw}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("expected at least 1 variable name")
+	}
+
+	variables := make([]*Variable, len(list))
+	for i, item := range list {
+		if _, ok := item.(string); !ok {
+			return nil, fmt.Errorf("unsupported variable name %v", item)
+		}
+		variable, err := decompileVariable(item)
+		if err != nil {
+			return nil, err
+		}
+		variables[i] = variable.(*Variable)
+	}
+	return variables, nil
+}
+
+// decompileIf converts {"if": [cond, a, cond, b, ..., else]}; a single
+// branch becomes IF ... THEN ... ELSE, several become CASE WHEN ... END
+func decompileIf(args []Expression) (Expression, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("if: expected at least 2 arguments, got %d", len(args))
+	}
+
+	ce := &ConditionalExpression{Token: NewToken(IF, "IF")}
+	for i := 0; i+1 < len(args); i += 2 {
+		ce.Branches = append(ce.Branches, ConditionalBranch{Condition: args[i], Result: args[i+1]})
+	}
+	if len(args)%2 == 1 {
+		ce.Else = args[len(args)-1]
+	}
+	if len(ce.Branches) > 1 {
+		ce.Token = NewToken(CASE, "CASE")
+	}
+	return ce, nil
+}
+
+// decompileQuantifier converts {"some": [collection, body]} and the all and
+// none forms. The variables of the body are relative to each element, which
+// is named item.
+func decompileQuantifier(operator string, args []Expression) (Expression, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s: expected 2 arguments, got %d", operator, len(args))
+	}
+
+	var token Token
+	for tokenType, op := range quantifierOperators {
+		if op == operator {
+			token = NewToken(tokenType, string(tokenType))
+		}
+	}
+	bindElement(args[1], "item")
+	return &QuantifierExpression{Token: token, Collection: args[0], Element: "item", Body: args[1]}, nil
+}
+
+// decompileCollection converts {"filter": [collection, body]} and
+// {"map": [collection, body]}; the element of the lambda is named item
+func decompileCollection(operator string, args []Expression) (Expression, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s: expected 2 arguments, got %d", operator, len(args))
+	}
+
+	token := NewToken(TokenType(strings.ToUpper(operator)), strings.ToUpper(operator))
+	bindElement(args[1], "item")
+	return &CollectionExpression{Token: token, Collection: args[0], Params: []string{"item"}, Body: args[1]}, nil
+}
+
+// decompileReduce converts {"reduce": [collection, body, initial]}. The
+// lambda's parameters are named after the keys its data holds them under,
+// (accumulator, current); JSONLogic's initial value defaults to null.
+func decompileReduce(args []Expression) (Expression, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("reduce: expected 3 arguments, got %d", len(args))
+	}
+
+	ce := &CollectionExpression{
+		Token:      NewToken(REDUCE, "REDUCE"),
+		Collection: args[0],
+		Params:     []string{"accumulator", "current"},
+		Body:       args[1],
+		Initial:    newLiteral(NewToken(NULL, "null"), nil),
+	}
+	if len(args) == 3 {
+		ce.Initial = args[2]
+	}
+
+	err := bindVariables(ce.Body, func(v *Variable) error {
+		if len(v.Path) == 0 || (v.Path[0] != "current" && v.Path[0] != "accumulator") {
+			return fmt.Errorf("reduce: %q is neither current nor accumulator", strings.Join(v.Path, "."))
+		}
+		bindVariable(v, v.Path[0], v.Path[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ce, nil
+}
+
+// bindElement makes the variables of a body refer to the element it is
+// evaluated against, named element
+func bindElement(body Expression, element string) {
+	bindVariables(body, func(v *Variable) error {
+		bindVariable(v, element, "")
+		return nil
+	})
+}
+
+// bindVariables calls bind for each variable a body evaluates against its
+// own data. Nested quantifiers and lambdas bind their own bodies; only their
+// collection and initial value are evaluated against this data.
+func bindVariables(body Expression, bind func(v *Variable) error) error {
+	var err error
+	inspect(body, func(node Expression) bool {
+		if err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *Variable:
+			err = bind(n)
+		case *QuantifierExpression:
+			err = bindVariables(n.Collection, bind)
+			return false
+		case *CollectionExpression:
+			if err = bindVariables(n.Collection, bind); err == nil && n.Initial != nil {
+				err = bindVariables(n.Initial, bind)
+			}
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// bindVariable makes v start from the scoped name, held under key in the data
+func bindVariable(v *Variable, name, key string) {
+	v.Scope, v.Key = name, key
+	v.Name = elementSource(v)
+	v.Token = NewToken(IDENTIFIER, v.Name)
+}
+
+// freeVariables returns the variables of node that are not bound to a quantifier element
+func freeVariables(node Expression) []*Variable {
+	var free []*Variable
+	inspect(node, func(node Expression) bool {
+		if v, ok := node.(*Variable); ok && v.Scope == "" {
+			free = append(free, v)
+		}
+		return true
+	})
+	return free
+}
+
+// decompileBinary converts a two-argument operator
+func decompileBinary(token Token, args []Expression) (Expression, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s: expected 2 arguments, got %d", token.Literal, len(args))
+	}
+	return newBinaryExpression(token, args[0], args[1]), nil
+}
+
+// decompileChain folds a variadic operator into a left-associative chain of
+// binary expressions, matching how the parser builds them
+func decompileChain(token Token, operator string, args []Expression) (Expression, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("%s: expected at least 2 arguments, got %d", operator, len(args))
+	}
+
+	left := args[0]
+	for _, right := range args[1:] {
+		left = newBinaryExpression(token, left, right)
+	}
+	return left, nil
+}
+
+// decompileCast converts the one-argument form {"+": [value]}, which JSONLogic
+// treats as a conversion to a number, as does {"*": [value]}. SUM(value)
+// compiles to it, except for arrays and values that may hold one, which SUM
+// adds up; those are written as value + 0, which converts the same way.
+func decompileCast(operator string, arg Expression) Expression {
+	if logic, err := Transform(arg); err == nil {
+		_, isArray := logic.([]interface{})
+		if _, isArrayArgument := arrayArgument([]interface{}{logic}); !isArray && !isArrayArgument {
+			return newFunctionCall(NewToken(IDENTIFIER, "SUM"), []Expression{arg})
+		}
+	}
+	zero := newLiteral(NewToken(NUMBER, "0"), int64(0))
+	return newBinaryExpression(NewToken(PLUS, "+"), arg, zero)
+}
+
+// decompileBetween converts the three-argument form {"<=": [lower, value, upper]}
+func decompileBetween(exclusive bool, args []Expression) Expression {
+	return &BetweenExpression{
+		Token:     NewToken(BETWEEN, "BETWEEN"),
+		Value:     args[1],
+		Lower:     args[0],
+		Upper:     args[2],
+		Exclusive: exclusive,
+	}
+}
+
+// decompileIn converts {"in": [needle, haystack]}. A literal string looked
+// for in anything but an array literal reads best as haystack CONTAINS needle.
+func decompileIn(args []Expression) (Expression, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("in: expected 2 arguments, got %d", len(args))
+	}
+	_, isString := stringLiteral(args[0])
+	if _, isArray := args[1].(*ArrayLiteral); isString && !isArray {
+		return newBinaryExpression(NewToken(CONTAINS, "CONTAINS"), args[1], args[0]), nil
+	}
+	return newBinaryExpression(NewToken(IN, "IN"), args[0], args[1]), nil
+}
+
+// decompileAffix recognises the substr comparisons STARTS_WITH and ENDS_WITH
+// lower to, {"==": [{"substr": [value, 0, n]}, prefix]} and
+// {"==": [{"substr": [value, -n]}, suffix]}
+func decompileAffix(args []Expression) (Expression, bool) {
+	if len(args) != 2 {
+		return nil, false
+	}
+	call, ok := args[0].(*FunctionCall)
+	affix, isString := stringLiteral(args[1])
+	if !ok || !isString || call.Function != "SUBSTR" {
+		return nil, false
+	}
+
+	n := strconv.Itoa(utf16Length(affix))
+	var token Token
+	switch {
+	case len(call.Arguments) == 3 && numberSource(call.Arguments[1]) == "0" && numberSource(call.Arguments[2]) == n:
+		token = NewToken(STARTS_WITH, "STARTS_WITH")
+	case len(call.Arguments) == 2 && numberSource(call.Arguments[1]) == "-"+n && n != "0":
+		token = NewToken(ENDS_WITH, "ENDS_WITH")
+	default:
+		return nil, false
+	}
+	return newBinaryExpression(token, call.Arguments[0], args[1]), true
+}
+
+// numberSource returns the source of a decompiled number, or "" for anything else
+func numberSource(exp Expression) string {
+	switch n := exp.(type) {
+	case *Literal:
+		if n.Token.Type == NUMBER {
+			return n.Token.Literal
+		}
+	}
+	return ""
+}
+
+// decompileArray converts a JSON array into an array literal
+func decompileArray(items []interface{}) (Expression, error) {
+	elements, err := decompileArgs(items)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrayLiteral{Token: NewToken(LBRACKET, "["), Elements: elements}, nil
+}
+
+// decompileNumber converts the decimal text of a number into a literal
+func decompileNumber(text string) (Expression, error) {
+	value, err := parseNumber(text)
+	if err != nil {
+		return nil, err
+	}
+	return newLiteral(NewToken(NUMBER, text), value), nil
+}
+
+// isVariablePath reports whether a JSONLogic var path can be written in REL:
+// every key must fit in brackets. Decompile checks that paths which are not
+// relative to a quantifier element start with a name.
+func isVariablePath(path []string) bool {
+	for _, segment := range path {
+		if segment == "" || (strings.Contains(segment, "'") && strings.Contains(segment, `"`)) {
+			return false
+		}
+	}
+	return true
+}
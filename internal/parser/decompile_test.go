@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecompile(t *testing.T) {
+	tests := []struct {
+		input    string // JSONLogic
+		expected string // REL
+	}{
+		{`{">": [{"var": "age"}, 18]}`, "@age > 18"},
+		{`{"and": [{">": [{"var": "age"}, 18]}, {"==": [{"var": "name"}, "John"]}]}`, "@age > 18 AND @name == 'John'"},
+		{`{"or": [{"var": "a"}, {"var": "b"}, {"var": "c"}]}`, "@a OR @b OR @c"},
+		{`{"and": [{"or": [{"var": "a"}, {"var": "b"}]}, {"var": "c"}]}`, "(@a OR @b) AND @c"},
+		{`{"in": [{"var": "status"}, ["active", "pending"]]}`, "@status IN ['active', 'pending']"},
+		{`{"!": [{"in": [{"var": "role"}, ["admin"]]}]}`, "@role NOT IN ['admin']"},
+		{`{"!": {"var": "isDeleted"}}`, "NOT @isDeleted"},
+		{`{"!": [{"==": [{"var": "a"}, 1]}]}`, "NOT (@a == 1)"},
+		{`{"*": [{"+": [{"var": "a"}, 1]}, 2]}`, "(@a + 1) * 2"},
+		{`{"===": [{"var": "n"}, 5]}`, "@n === 5"},
+		{`{"and": [{"!==": [{"var": "a"}, "5"]}, {"!": {"===": [{"var": "b"}, null]}}]}`, "@a !== '5' AND NOT (@b === null)"},
+		{`{"+": [5]}`, "SUM(5)"},
+		{`{"*": "3"}`, "SUM('3')"},
+		{`{"+": [{"var": "x"}]}`, "@x + 0"},
+		{`{"+": [1, {"var": "a"}, 3]}`, "SUM(1, @a, 3)"},
+		{`{"-": [{"var": "a"}, {"-": [{"var": "b"}, 1]}]}`, "@a - (@b - 1)"},
+		{`{"<": [{"-": [{"var": "delta"}]}, -2.5]}`, "-@delta < -2.5"},
+		{`{"==": [{"var": "flag"}, true]}`, "@flag == true"},
+		{`{"!=": [{"var": "deletedAt"}, null]}`, "@deletedAt != null"},
+		{`{"==": [{"var": "name"}, "O'Brien"]}`, `@name == "O'Brien"`},
+		{`{"log": [{"var": "a"}]}`, "LOG(@a)"},
+		{`{"max": [{"var": "a"}, 1]}`, "MAX(@a, 1)"},
+		{`{"if": [{"var": "a"}, 1, 2]}`, "IF @a THEN 1 ELSE 2"},
+		{`{"some": [{"var": "cart"}, {">": [{"var": "price"}, 100]}]}`, "ANY @cart AS item: item.price > 100"},
+		{`{"none": [{"var": "tags"}, {"==": [{"var": ""}, "spam"]}]}`, "NONE @tags AS item: item == 'spam'"},
+		{`{"all": [{"var": "orders"}, {"some": [{"var": "lines"}, {">": [{"var": "0"}, 1]}]}]}`, "ALL @orders AS item: ANY item.lines AS item: item[0] > 1"},
+		{`{"and": [{"some": [{"var": "xs"}, {"var": "ok"}]}, {"var": "vip"}]}`, "(ANY @xs AS item: item.ok) AND @vip"},
+		{`{"if": [{"var": "a"}, {"if": [{"var": "b"}, 1]}, 2]}`, "IF @a THEN (IF @b THEN 1) ELSE 2"},
+		{`{"if": [{"var": "a"}, 1, {"var": "b"}, 2]}`, "CASE WHEN @a THEN 1 WHEN @b THEN 2 END"},
+		{`{"+": [{"if": [{"var": "a"}, 1, 2]}, 3]}`, "(IF @a THEN 1 ELSE 2) + 3"},
+		{`{"filter": [{"var": "orders"}, {"var": "paid"}]}`, "FILTER(@orders, item -> item.paid)"},
+		{`{"reduce": [{"var": "xs"}, {"+": [{"var": "accumulator"}, {"var": "current.n"}]}, 0]}`, "REDUCE(@xs, (accumulator, current) -> accumulator + current.n, 0)"},
+		{`{"reduce": [{"var": "xs"}, {"var": "current"}]}`, "REDUCE(@xs, (accumulator, current) -> current, null)"},
+		{`{"merge": [[1], {"var": "a"}]}`, "MERGE([1], @a)"},
+		{`{"in": ["@", {"var": "email"}]}`, "@email CONTAINS '@'"},
+		{`{"in": [{"var": "a"}, {"var": "b"}]}`, "@a IN @b"},
+		{`{"==": [{"substr": [{"var": "sku"}, 0, 4]}, "ABC-"]}`, "@sku STARTS_WITH 'ABC-'"},
+		{`{"==": [{"substr": [{"var": "sku"}, 0, 3]}, "ABC-"]}`, "SUBSTR(@sku, 0, 3) == 'ABC-'"},
+		{`{"==": [{"substr": [{"var": "email"}, -4]}, ".com"]}`, "@email ENDS_WITH '.com'"},
+		{`{"==": [{"substr": [{"var": "x"}, 0, 2]}, "😀"]}`, "@x STARTS_WITH '😀'"},
+		{`{"==": [{"substr": [{"var": "x"}, 0, 1]}, "😀"]}`, "SUBSTR(@x, 0, 1) == '😀'"},
+		{`{"cat": ["a", {"var": "b"}]}`, "CAT('a', @b)"},
+		{`{"==": [{"var": "note"}, "line\nbreak \\ \u0007"]}`, `@note == 'line\nbreak \\ \u0007'`},
+		{`{">": [{"date": [{"var": "createdAt"}]}, {"-": [{"now": []}, 86400000]}]}`, "DATE(@createdAt) > NOW() - 86400000"},
+		{`{"matches": [{"var": "email"}, "@corp$"]}`, "@email MATCHES '@corp$'"},
+		{`{"!": {"like": [{"var": "name"}, "Jo%"]}}`, "NOT (@name LIKE 'Jo%')"},
+		{`{"missing": "email"}`, "@email IS MISSING"},
+		{`{"!": {"missing": ["user.email"]}}`, "EXISTS @user.email"},
+		{`{"missing": ["a", "b"]}`, "MISSING_SOME(2, [@a, @b])"},
+		{`{"missing_some": [1, ["a", "b"]]}`, "MISSING_SOME(1, [@a, @b])"},
+		{`{">": [{"var": ["age", 0]}, 18]}`, "@age ?? 0 > 18"},
+		{`{"var": ["a", {"var": ["b", 0]}]}`, "@a ?? (@b ?? 0)"},
+		{`{"==": [{"var": "items.0.price"}, {"var": "user.first-name"}]}`, "@items[0].price == @user['first-name']"},
+		{`{"==": [{"var": "a.b]c"}, {"var": "d.'e]"}]}`, `@a['b]c'] == @d["'e]"]`},
+		{`{"<=": [18, {"var": "age"}, 65]}`, "@age BETWEEN 18 AND 65"},
+		{`{"!": {"<": [0, {"var": "t"}, 100]}}`, "@t NOT BETWEEN 0 AND 100 EXCLUSIVE"},
+		{`{"==": [{"<=": [1, {"var": "a"}, 2]}, true]}`, "(@a BETWEEN 1 AND 2) == true"},
+	}
+
+	for i, tt := range tests {
+		var logic interface{}
+		if err := json.Unmarshal([]byte(tt.input), &logic); err != nil {
+			t.Fatalf("test[%d] - invalid test JSON: %v", i, err)
+		}
+
+		expression, err := Decompile(logic)
+		if err != nil {
+			t.Errorf("test[%d] - Decompile() failed: %v", i, err)
+			continue
+		}
+
+		if got := Print(expression); got != tt.expected {
+			t.Errorf("test[%d] - wrong REL. got=%q, want=%q", i, got, tt.expected)
+		}
+	}
+}
+
+func TestDecompileRoundTrip(t *testing.T) {
+	tests := []string{
+		"@age > 18 AND @name == 'John'",
+		"@role NOT IN ['admin', 'moderator'] OR (@a + @b) % 2 == 0",
+		"NOT (@a > 1 AND @b < 2)",
+		"@price * @qty > 100 AND @isActive == true",
+		"CASE WHEN @total >= 1000 THEN 'gold' WHEN @total >= 500 THEN 'silver' ELSE 'bronze' END == 'gold'",
+		"IF @a THEN 1 ELSE IF @b THEN 2 ELSE 3",
+		"(ANY @cart AS item: item.price > 100 AND item['unit price'] < 5) OR @vip",
+		"@email ENDS_WITH '@example.com' OR @sku STARTS_WITH 'ABC-' OR @title CONTAINS 'admin'",
+		"REDUCE(FILTER(@orders, item -> item.status == 'paid'), (accumulator, current) -> accumulator + current.total, 0) > 500",
+		"ANY MAP(@orders, item -> item.lines) AS item: ANY item AS item: item.qty > 1",
+		"EXISTS @email OR @phone IS MISSING OR NOT MISSING_SOME(1, [@a, @b])",
+		"@email MATCHES '^[a-z]+@corp' AND @name LIKE 'Jo%'",
+		"@isActive === true AND @n !== '5' OR @a == @b",
+		`@title == "it's \"quoted\"" OR @body CONTAINS '\\n\t'`,
+		"@user.address.city == 'Pune' OR @items[0]['unit price'] > 10",
+		`@a['b]c'] == @d["'e]"][0]`,
+		"@age BETWEEN 18 AND @limit + 1 AND @score NOT BETWEEN 0 AND 10 EXCLUSIVE",
+		"@a IN [1 + 2, 3] OR MERGE([@b == 2], [-@c]) == []",
+	}
+
+	for i, input := range tests {
+		p := NewParser(NewLexer(input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Fatalf("test[%d] - ParseProgram() failed: %v", i, p.Errors())
+		}
+
+		logic, err := Transform(expression)
+		if err != nil {
+			t.Fatalf("test[%d] - Transform() failed: %v", i, err)
+		}
+		encoded, _ := json.Marshal(logic)
+
+		var decoded interface{}
+		json.Unmarshal(encoded, &decoded)
+		decompiled, err := Decompile(decoded)
+		if err != nil {
+			t.Errorf("test[%d] - Decompile() failed: %v", i, err)
+			continue
+		}
+
+		if got := Print(decompiled); got != input {
+			t.Errorf("test[%d] - round trip changed the rule. got=%q, want=%q", i, got, input)
+		}
+	}
+}
+
+func TestDecompileAggregates(t *testing.T) {
+	tests := []string{
+		"SUM(5)",
+		"SUM(@a, @b)",
+		"SUM(@a, @b, 3)",
+		"SUM(@items)",
+		"AVG(3)",
+		"AVG(@a, @b)",
+		"AVG(@a, @b, @c) >= 75",
+		"AVG(@scores)",
+	}
+
+	for i, input := range tests {
+		p := NewParser(NewLexer(input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Fatalf("test[%d] - ParseProgram() failed: %v", i, p.Errors())
+		}
+		logic, err := Transform(expression)
+		if err != nil {
+			t.Fatalf("test[%d] - Transform() failed: %v", i, err)
+		}
+		encoded, _ := json.Marshal(logic)
+
+		var decoded interface{}
+		json.Unmarshal(encoded, &decoded)
+		decompiled, err := Decompile(decoded)
+		if err != nil {
+			t.Errorf("test[%d] - Decompile(%s) failed: %v", i, encoded, err)
+			continue
+		}
+
+		// The decompiled rule need not read the same, but must compile the same
+		again, err := Transform(decompiled)
+		if err != nil {
+			t.Errorf("test[%d] - Transform(%q) failed: %v", i, Print(decompiled), err)
+			continue
+		}
+		if reencoded, _ := json.Marshal(again); string(reencoded) != string(encoded) {
+			t.Errorf("test[%d] - %q compiles differently.\ngot:  %s\nwant: %s", i, Print(decompiled), reencoded, encoded)
+		}
+	}
+}
+
+func TestDecompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"method": [{"var": "name"}, "toUpperCase"]}`, `unsupported JSONLogic operator "method"`},
+		{`{"some": [{"var": "items"}]}`, "some: expected 2 arguments, got 1"},
+		{`{"reduce": [{"var": "xs"}, {"var": "total"}, 0]}`, `reduce: "total" is neither current nor accumulator`},
+		{`{"==": [{"var": ""}, 1]}`, `var: "" is not a valid REL variable name`},
+		{`{"and": [{"method": ["a", "trim"]}, true]}`, `and: unsupported JSONLogic operator "method"`},
+		{`{">": [1]}`, "expected 2 arguments"},
+		{`{"==": [1, 2], "!=": [1, 2]}`, "exactly one key"},
+	}
+
+	for i, tt := range tests {
+		var logic interface{}
+		json.Unmarshal([]byte(tt.input), &logic)
+
+		_, err := Decompile(logic)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("test[%d] - wrong error. got=%v, want containing %q", i, err, tt.expected)
+		}
+	}
+}
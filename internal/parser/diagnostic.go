@@ -0,0 +1,101 @@
+package parser
+
+import "fmt"
+
+// Severity indicates how serious a diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// DiagnosticCode is a stable, machine-readable identifier for a class of diagnostic
+type DiagnosticCode string
+
+const (
+	E_UNEXPECTED_TOKEN   DiagnosticCode = "E_UNEXPECTED_TOKEN"
+	E_EXPECTED_TOKEN     DiagnosticCode = "E_EXPECTED_TOKEN"
+	E_INVALID_EXPRESSION DiagnosticCode = "E_INVALID_EXPRESSION"
+	E_TRAILING_INPUT     DiagnosticCode = "E_TRAILING_INPUT"
+
+	// Function call errors
+	E_UNKNOWN_FUNCTION DiagnosticCode = "E_UNKNOWN_FUNCTION"
+	E_ARGUMENT_COUNT   DiagnosticCode = "E_ARGUMENT_COUNT"
+	E_ARGUMENT_TYPE    DiagnosticCode = "E_ARGUMENT_TYPE"
+
+	// Literal errors
+	E_INVALID_NUMBER   DiagnosticCode = "E_INVALID_NUMBER"
+	E_INVALID_PATTERN  DiagnosticCode = "E_INVALID_PATTERN"
+	E_INVALID_DATE     DiagnosticCode = "E_INVALID_DATE"
+	E_INVALID_DURATION DiagnosticCode = "E_INVALID_DURATION"
+
+	// Lexical errors
+	E_ILLEGAL_CHARACTER    DiagnosticCode = "E_ILLEGAL_CHARACTER"
+	E_UNTERMINATED_STRING  DiagnosticCode = "E_UNTERMINATED_STRING"
+	E_INVALID_ESCAPE       DiagnosticCode = "E_INVALID_ESCAPE"
+	E_UNTERMINATED_COMMENT DiagnosticCode = "E_UNTERMINATED_COMMENT"
+	E_INVALID_VARIABLE     DiagnosticCode = "E_INVALID_VARIABLE"
+	E_INVALID_UTF8         DiagnosticCode = "E_INVALID_UTF8"
+)
+
+// SuggestedFix describes an edit that would resolve a diagnostic: the text
+// covered by Span should be replaced with Replacement
+type SuggestedFix struct {
+	Message     string `json:"message"`
+	Span        Span   `json:"span"`
+	Replacement string `json:"replacement"`
+}
+
+// Diagnostic is a single problem found while processing REL source
+type Diagnostic struct {
+	Severity Severity       `json:"severity"`
+	Code     DiagnosticCode `json:"code"`
+	Message  string         `json:"message"`
+	Span     Span           `json:"span"`
+	Expected []TokenType    `json:"expected,omitempty"`
+	Fix      *SuggestedFix  `json:"fix,omitempty"`
+}
+
+// String formats the diagnostic as "line:column: message"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Message)
+}
+
+// newErrorDiagnostic creates an error diagnostic covering the given token
+func newErrorDiagnostic(tok Token, code DiagnosticCode, msg string) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  msg,
+		Span:     tok.Span(),
+	}
+}
+
+// newExpectedDiagnostic creates an E_EXPECTED_TOKEN diagnostic at the given
+// token. When a single punctuation token is expected, inserting it before the
+// offending token is offered as a fix.
+func newExpectedDiagnostic(tok Token, msg string, expected ...TokenType) Diagnostic {
+	d := newErrorDiagnostic(tok, E_EXPECTED_TOKEN, msg)
+	d.Expected = expected
+
+	if len(expected) == 1 && isPunctuation(expected[0]) {
+		insert := string(expected[0])
+		d.Fix = &SuggestedFix{
+			Message:     fmt.Sprintf("insert '%s'", insert),
+			Span:        Span{Start: tok.Start, End: tok.Start},
+			Replacement: insert,
+		}
+	}
+	return d
+}
+
+// isPunctuation reports whether a token type is spelled exactly like its literal
+func isPunctuation(t TokenType) bool {
+	switch t {
+	case COMMA, SEMICOLON, LPAREN, RPAREN, LBRACKET, RBRACKET, LBRACE, RBRACE:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,689 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evaluator runs REL expressions directly against input data. Its semantics
+// mirror running the output of Transform through a JSONLogic engine, so a rule
+// produces the same result whichever way it is executed.
+type Evaluator struct {
+	// Logger receives the values passed to LOG(); defaults to the standard logger
+	Logger *log.Logger
+
+	// Clock returns the time used by NOW(); defaults to time.Now. Tests can
+	// set it to a fixed time.
+	Clock func() time.Time
+}
+
+// NewEvaluator creates an Evaluator with default settings
+func NewEvaluator() *Evaluator {
+	return &Evaluator{Logger: log.Default()}
+}
+
+// Evaluate evaluates an expression against data using a default Evaluator
+func Evaluate(expr Expression, data map[string]interface{}) (interface{}, error) {
+	return NewEvaluator().Evaluate(expr, data)
+}
+
+// Evaluate walks the AST and computes the value of the expression for the given data
+func (e *Evaluator) Evaluate(expr Expression, data map[string]interface{}) (interface{}, error) {
+	return e.eval(expr, data)
+}
+
+// eval computes the value of node. Variables are looked up in data, which is
+// the input, or the current element inside the body of a quantifier.
+func (e *Evaluator) eval(node Expression, data interface{}) (interface{}, error) {
+	if node == nil {
+		return nil, fmt.Errorf("cannot evaluate nil node")
+	}
+
+	switch n := node.(type) {
+	case *BinaryExpression:
+		return e.evalBinaryExpression(n, data)
+	case *UnaryExpression:
+		return e.evalUnaryExpression(n, data)
+	case *Variable:
+		return lookupVariable(n.Path, data), nil
+	case *Literal:
+		return transformLiteral(n)
+	case *ArrayLiteral:
+		return e.evalArrayLiteral(n, data)
+	case *FunctionCall:
+		return e.evalFunctionCall(n, data)
+	case *BetweenExpression:
+		return e.evalBetweenExpression(n, data)
+	case *DefaultExpression:
+		return e.evalDefaultExpression(n, data)
+	case *MissingExpression:
+		return e.evalMissingExpression(n, data)
+	case *ConditionalExpression:
+		return e.evalConditionalExpression(n, data)
+	case *QuantifierExpression:
+		return e.evalQuantifierExpression(n, data)
+	case *CollectionExpression:
+		return e.evalCollectionExpression(n, data)
+	case *BadExpression:
+		return nil, fmt.Errorf("cannot evaluate invalid expression at %s", n.Span.Start)
+	default:
+		return nil, fmt.Errorf("unsupported node type: %T", n)
+	}
+}
+
+// evalBinaryExpression handles binary operations (AND, OR, comparisons, arithmetic)
+func (e *Evaluator) evalBinaryExpression(be *BinaryExpression, data interface{}) (interface{}, error) {
+	left, err := e.eval(be.Left, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// AND and OR short-circuit and, like JSONLogic, return the deciding operand
+	switch be.Operator {
+	case "AND":
+		if !Truthy(left) {
+			return left, nil
+		}
+		return e.eval(be.Right, data)
+	case "OR":
+		if Truthy(left) {
+			return left, nil
+		}
+		return e.eval(be.Right, data)
+	}
+
+	right, err := e.eval(be.Right, data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch be.Token.Type {
+	case CONTAINS:
+		return contains(left, right), nil
+	case STARTS_WITH:
+		return strings.HasPrefix(toString(left), toString(right)), nil
+	case ENDS_WITH:
+		return strings.HasSuffix(toString(left), toString(right)), nil
+	}
+
+	switch be.Operator {
+	case "=", "==":
+		return looseEquals(left, right), nil
+	case "===":
+		return strictEquals(left, right), nil
+	case "!=":
+		return !looseEquals(left, right), nil
+	case "!==":
+		return !strictEquals(left, right), nil
+	case ">", "<", ">=", "<=":
+		return compare(be.Operator, left, right), nil
+	case "IN":
+		return contains(right, left), nil
+	case "+":
+		return toNumber(left) + toNumber(right), nil
+	case "-":
+		return toNumber(left) - toNumber(right), nil
+	case "*":
+		return toNumber(left) * toNumber(right), nil
+	case "/":
+		return toNumber(left) / toNumber(right), nil
+	case "%":
+		return math.Mod(toNumber(left), toNumber(right)), nil
+	default:
+		return nil, fmt.Errorf("unsupported binary operator: %s", be.Operator)
+	}
+}
+
+// evalBetweenExpression checks lower <= value <= upper, or < for an exclusive range
+func (e *Evaluator) evalBetweenExpression(be *BetweenExpression, data interface{}) (interface{}, error) {
+	values := make([]interface{}, 3)
+	for i, operand := range []Expression{be.Lower, be.Value, be.Upper} {
+		value, err := e.eval(operand, data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	operator := "<="
+	if be.Exclusive {
+		operator = "<"
+	}
+	return compare(operator, values[0], values[1]) && compare(operator, values[1], values[2]), nil
+}
+
+// evalDefaultExpression returns the first variable that is present, falling
+// back to the last operand. Like the JSONLogic Transform emits, every
+// variable but the last counts as missing when it is null or "" (JSONLogic's
+// missing), while the last only falls back when absent (var's default).
+func (e *Evaluator) evalDefaultExpression(de *DefaultExpression, data interface{}) (interface{}, error) {
+	n := len(de.Operands)
+	for i, operand := range de.Operands[:n-1] {
+		v, ok := operand.(*Variable)
+		if !ok {
+			return nil, fmt.Errorf("only a variable can have a default value, got %T", operand)
+		}
+
+		value, found := lookupPath(v.Path, data)
+		if i < n-2 && !isMissing(value) {
+			return value, nil
+		}
+		if i == n-2 && found {
+			return value, nil
+		}
+	}
+	return e.eval(de.Operands[n-1], data)
+}
+
+// evalConditionalExpression evaluates the result of the first branch whose
+// condition is truthy, or the ELSE value
+func (e *Evaluator) evalConditionalExpression(ce *ConditionalExpression, data interface{}) (interface{}, error) {
+	for _, branch := range ce.Branches {
+		condition, err := e.eval(branch.Condition, data)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(condition) {
+			return e.eval(branch.Result, data)
+		}
+	}
+
+	if ce.Else == nil {
+		return nil, nil
+	}
+	return e.eval(ce.Else, data)
+}
+
+// evalQuantifierExpression evaluates the body against each element of the
+// collection, like JSONLogic's some, all and none. A value that is not an
+// array has no elements.
+func (e *Evaluator) evalQuantifierExpression(qe *QuantifierExpression, data interface{}) (interface{}, error) {
+	collection, err := e.eval(qe.Collection, data)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := asArray(collection)
+
+	matched := 0
+	for _, item := range items {
+		result, err := e.eval(qe.Body, item)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(result) {
+			matched++
+		}
+	}
+
+	switch qe.Token.Type {
+	case ANY:
+		return matched > 0, nil
+	case ALL:
+		return len(items) > 0 && matched == len(items), nil
+	default:
+		return matched == 0, nil
+	}
+}
+
+// evalCollectionExpression runs FILTER, MAP and REDUCE like JSONLogic's
+// filter, map and reduce. The lambda of REDUCE sees the data
+// {"current": element, "accumulator": value}.
+func (e *Evaluator) evalCollectionExpression(ce *CollectionExpression, data interface{}) (interface{}, error) {
+	collection, err := e.eval(ce.Collection, data)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := asArray(collection)
+
+	if ce.Token.Type == REDUCE {
+		accumulator, err := e.eval(ce.Initial, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			scope := map[string]interface{}{"current": item, "accumulator": accumulator}
+			if accumulator, err = e.eval(ce.Body, scope); err != nil {
+				return nil, err
+			}
+		}
+		return accumulator, nil
+	}
+
+	results := []interface{}{}
+	for _, item := range items {
+		result, err := e.eval(ce.Body, item)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ce.Token.Type == MAP:
+			results = append(results, result)
+		case Truthy(result):
+			results = append(results, item)
+		}
+	}
+	return results, nil
+}
+
+// evalMissingExpression returns the paths of the missing variables, like
+// JSONLogic's missing. With a Need, the result is empty when at least that
+// many variables are present, like missing_some.
+func (e *Evaluator) evalMissingExpression(me *MissingExpression, data interface{}) (interface{}, error) {
+	missing := []interface{}{}
+	for _, v := range me.Variables {
+		if isMissing(lookupVariable(v.Path, data)) {
+			missing = append(missing, strings.Join(v.Path, "."))
+		}
+	}
+
+	if me.Need != nil {
+		need, err := e.eval(me.Need, data)
+		if err != nil {
+			return nil, err
+		}
+		if float64(len(me.Variables)-len(missing)) >= toNumber(need) {
+			missing = []interface{}{}
+		}
+	}
+
+	if me.Negated {
+		return !Truthy(missing), nil
+	}
+	return missing, nil
+}
+
+// isMissing implements JSONLogic's missing test for a looked up value
+func isMissing(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// evalUnaryExpression handles unary operations (NOT, !, -)
+func (e *Evaluator) evalUnaryExpression(ue *UnaryExpression, data interface{}) (interface{}, error) {
+	right, err := e.eval(ue.Right, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if ue.Operator == "-" {
+		return -toNumber(right), nil
+	}
+	return !Truthy(right), nil
+}
+
+// evalArrayLiteral handles array literals
+func (e *Evaluator) evalArrayLiteral(al *ArrayLiteral, data interface{}) (interface{}, error) {
+	elements := make([]interface{}, len(al.Elements))
+	for i, elem := range al.Elements {
+		value, err := e.eval(elem, data)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = value
+	}
+	return elements, nil
+}
+
+// evalFunctionCall handles calls to LOG, NOW and the functions in the registry
+func (e *Evaluator) evalFunctionCall(fc *FunctionCall, data interface{}) (interface{}, error) {
+	fn, err := resolveFunction(fc)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(fc.Arguments))
+	for i, arg := range fc.Arguments {
+		value, err := e.eval(arg, data)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	if fn.Name == "LOG" {
+		var value interface{}
+		if len(args) > 0 {
+			value = args[0]
+		}
+		if e.Logger != nil {
+			e.Logger.Println(value)
+		}
+		return value, nil
+	}
+
+	if fn.Name == "NOW" {
+		if e.Clock != nil {
+			return epochMillis(e.Clock()), nil
+		}
+		return epochMillis(time.Now()), nil
+	}
+
+	if fn.Eval == nil {
+		return nil, fmt.Errorf("function %s cannot be evaluated", fn.Name)
+	}
+	return fn.Eval(args)
+}
+
+// Truthy implements JSONLogic truthiness: false, null, 0, NaN, "" and empty
+// arrays are falsy, everything else is truthy
+func Truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	}
+
+	if n, ok := asNumber(v); ok {
+		return n != 0 && !math.IsNaN(n)
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// asNumber converts Go numeric types (including json.Number) to float64
+func asNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// asInteger converts Go integer types, and json.Number holding an integer, to
+// int64. Comparing two integers this way keeps IDs above 2^53 distinct.
+func asInteger(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), uint64(n) <= math.MaxInt64
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toNumber converts a value to a number the way JavaScript's Number() does;
+// values that have no numeric interpretation become NaN
+func toNumber(v interface{}) float64 {
+	if n, ok := asNumber(v); ok {
+		return n
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if val {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(val)
+		if s == "" {
+			return 0
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+		return math.NaN()
+	case []interface{}:
+		if len(val) == 0 {
+			return 0
+		}
+		if len(val) == 1 {
+			return toNumber(val[0])
+		}
+	}
+	return math.NaN()
+}
+
+// toString converts a primitive value to its JavaScript string form
+func toString(v interface{}) string {
+	if n, ok := asNumber(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, elem := range val {
+			if elem != nil {
+				parts[i] = toString(elem)
+			}
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// looseEquals implements JavaScript's == as used by JSONLogic's "==" operator
+func looseEquals(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if ai, ok := asInteger(a); ok {
+		if bi, ok := asInteger(b); ok {
+			return ai == bi
+		}
+	}
+	an, aIsNum := asNumber(a)
+	bn, bIsNum := asNumber(b)
+	if aIsNum && bIsNum {
+		return an == bn
+	}
+
+	switch av := a.(type) {
+	case bool:
+		return looseEquals(toNumber(av), b)
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return av == bv
+		case bool:
+			return looseEquals(a, toNumber(bv))
+		case []interface{}:
+			return av == toString(bv)
+		}
+		if bIsNum {
+			return toNumber(av) == bn
+		}
+	case []interface{}:
+		if _, ok := b.([]interface{}); ok {
+			// arrays are only equal by identity in JavaScript
+			return false
+		}
+		return looseEquals(toString(av), b)
+	}
+
+	if aIsNum {
+		return looseEquals(b, a)
+	}
+	return false
+}
+
+// strictEquals implements JavaScript's ===, used by === and !== and for
+// array membership
+func strictEquals(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if ai, ok := asInteger(a); ok {
+		if bi, ok := asInteger(b); ok {
+			return ai == bi
+		}
+	}
+	an, aIsNum := asNumber(a)
+	bn, bIsNum := asNumber(b)
+	if aIsNum || bIsNum {
+		return aIsNum && bIsNum && an == bn
+	}
+
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	default:
+		return false
+	}
+}
+
+// compare implements JavaScript's relational operators: two strings compare
+// lexically, anything else compares numerically
+func compare(operator string, a, b interface{}) bool {
+	as, aIsString := a.(string)
+	bs, bIsString := b.(string)
+	if aIsString && bIsString {
+		switch operator {
+		case ">":
+			return as > bs
+		case "<":
+			return as < bs
+		case ">=":
+			return as >= bs
+		default:
+			return as <= bs
+		}
+	}
+
+	if ai, ok := asInteger(a); ok {
+		if bi, ok := asInteger(b); ok {
+			return compareIntegers(operator, ai, bi)
+		}
+	}
+
+	an, bn := toNumber(a), toNumber(b)
+	switch operator {
+	case ">":
+		return an > bn
+	case "<":
+		return an < bn
+	case ">=":
+		return an >= bn
+	default:
+		return an <= bn
+	}
+}
+
+// compareIntegers applies a relational operator to two integers
+func compareIntegers(operator string, a, b int64) bool {
+	switch operator {
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	case ">=":
+		return a >= b
+	default:
+		return a <= b
+	}
+}
+
+// contains implements JSONLogic's "in": membership for arrays and substring
+// search for strings
+func contains(haystack, needle interface{}) bool {
+	switch h := haystack.(type) {
+	case []interface{}:
+		for _, item := range h {
+			if strictEquals(item, needle) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return strings.Contains(h, toString(needle))
+	}
+
+	// Slices built by Go callers, e.g. []string
+	if items, ok := asArray(haystack); ok {
+		for _, item := range items {
+			if strictEquals(item, needle) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// asArray converts any Go slice to []interface{}
+func asArray(v interface{}) ([]interface{}, bool) {
+	if items, ok := v.([]interface{}); ok {
+		return items, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
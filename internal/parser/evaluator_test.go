@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	data := map[string]interface{}{
+		"age":       float64(21),
+		"name":      "John",
+		"status":    "active",
+		"isActive":  true,
+		"price":     12.5,
+		"qty":       4,
+		"zero":      0,
+		"deletedAt": nil,
+		"scores":    []interface{}{70, 80, 90},
+		"user": map[string]interface{}{
+			"first-name": "Ann",
+			"address":    map[string]interface{}{"city": "Pune"},
+		},
+		"größe":  180,
+		"名前":     "太郎",
+		"orders": []map[string]interface{}{{"total": 30}},
+		"cart": []interface{}{
+			map[string]interface{}{"sku": "a", "price": 150},
+			map[string]interface{}{"sku": "b", "price": 20},
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"@age > 18", true},
+		{"@age > 18 AND @name == 'John'", true},
+		{"@age < 18 OR @name == 'Jane'", false},
+		{"@status IN ['active', 'pending']", true},
+		{"@role NOT IN ['admin', 'moderator']", true},
+		{"NOT @isActive", false},
+		{"@isActive === true", true},
+		{"5 === '5'", false},
+		{"5 == '5' AND 5 !== '5' AND @age === 21 AND NOT (@age !== 21.0)", true},
+		{"null === null AND @missing !== 0 AND '' !== false", true},
+		{"@age == '21'", true},
+		{"@deletedAt == null", true},
+		{"@missing == null", true},
+		{"@price * @qty > 49", true},
+		{"(@qty + 1) % 2", float64(1)},
+		{"-@age", float64(-21)},
+		{"@name > 'Jane'", true},
+		{"@user.address.city == 'Pune'", true},
+		{`@user["first-name"]`, "Ann"},
+		{"@orders[0].total", 30},
+		{"@scores[2] - @scores.0", float64(20)},
+		{"@orders[1].total == null", true},
+		{"@user.address.city.zip == null", true},
+		{"@missing ?? 5", 5},
+		{"@deletedAt ?? 5", nil},
+		{"DEFAULT(@age, 0) > 18", true},
+		{"@user.nickname ?? @user['first-name'] ?? 'guest'", "Ann"},
+		{"@deletedAt ?? @missing ?? 'guest'", "guest"},
+		{"@missing IS MISSING", []interface{}{"missing"}},
+		{"@deletedAt IS NOT MISSING", false},
+		{"EXISTS @user.address.city", true},
+		{"MISSING_SOME(1, [@missing, @name])", []interface{}{}},
+		{"MISSING_SOME(2, [@missing, @name])", []interface{}{"missing"}},
+		{"IF @age >= 21 THEN 'adult' ELSE 'minor'", "adult"},
+		{"IF @zero THEN 1", nil},
+		{"CASE WHEN @age < 13 THEN 'child' WHEN @age < 20 THEN 'teen' ELSE 'adult' END", "adult"},
+		{"CASE WHEN @age < 13 THEN 'child' WHEN @age < 30 THEN 'young' END", "young"},
+		{"ANY @cart AS item: item.price > 100", true},
+		{"ALL @cart AS item: item.price > 100", false},
+		{"NONE @cart AS item: item.sku == 'c'", true},
+		{"ALL @scores AS s: s >= 70", true},
+		{"ALL @missing AS s: s >= 70", false},
+		{"NONE @missing AS s: s", true},
+		{"ANY @orders AS o: o.total BETWEEN 10 AND 50", true},
+		{"ANY [[1, 2], [3]] AS row: ANY row AS n: n == 3", true},
+		{"FILTER(@cart, item -> item.price > 100)", []interface{}{map[string]interface{}{"sku": "a", "price": 150}}},
+		{"MAP(@cart, item -> item.sku)", []interface{}{"a", "b"}},
+		{"MAP(@missing, x -> x)", []interface{}{}},
+		{"REDUCE(@cart, (sum, item) -> sum + item.price, 0)", float64(170)},
+		{"REDUCE(@missing, (sum, item) -> sum + item, 7)", float64(7)},
+		{"SUM(MAP(FILTER(@cart, i -> i.price < 100), i -> i.price))", float64(20)},
+		{"MERGE(@scores, [1], 2)", []interface{}{70, 80, 90, float64(1), float64(2)}},
+		{"@name CONTAINS 'oh'", true},
+		{"@scores CONTAINS 80", true},
+		{"'act' IN @status", true},
+		{"'admin' IN @roles", false},
+		{"@name STARTS_WITH 'Jo' AND @name ENDS_WITH 'hn'", true},
+		{"@name ENDS_WITH 'Jo'", false},
+		{"@name ENDS_WITH ''", true},
+		{"@age STARTS_WITH '2'", true},
+		{"@name MATCHES '^J[a-z]+$'", true},
+		{"@user.address.city MATCHES 'pune'", false},
+		{"@age MATCHES '^2'", true},
+		{"@name LIKE 'Jo%'", true},
+		{"@name LIKE 'J_hn' AND @name LIKE '%hn' AND @name LIKE '%'", true},
+		{"@name LIKE 'jo%'", false},
+		{"'50% off' LIKE '50\\% %'", true},
+		{"'a.b' LIKE 'a_b' AND NOT ('axb' LIKE 'a.b')", true},
+		{"CAT(@name, ' is ', @age, '/', @isActive)", "John is 21/true"},
+		{"SUBSTR(@name, 1)", "ohn"},
+		{"SUBSTR(@name, -3, 2)", "oh"},
+		{"SUBSTR(@name, 1, -1)", "oh"},
+		{"SUBSTR(@name, 5)", ""},
+		{"SUBSTR('😀ab', 2) == 'ab' AND SUBSTR('a😀b', 1, 2) == '😀' AND SUBSTR('a😀b', -1) == 'b'", true},
+		{"'😀ab' STARTS_WITH '😀' AND 'ab😀' ENDS_WITH 'b😀'", true},
+		{"MAX(@age, @price, 3)", float64(21)},
+		{"MIN([@age, @qty])", float64(4)},
+		{"MAX(@scores)", float64(90)},
+		{"SUM(@scores) == 240", true},
+		{"AVG(@scores)", float64(80)},
+		{"AVG(@age, @qty) == 12.5", true},
+		{"MAX(@age, @name)", math.NaN()},
+		{"9007199254740993 == 9007199254740992", false},
+		{"9007199254740993 > 9007199254740992", true},
+		{"123456789012345678901 == 123456789012345678901 AND 123456789012345678901 > 9223372036854775807", true},
+		{"0x1F == 31 AND 1e3 === 1_000 AND -3 < -2.5", true},
+		{"@größe > 170 AND @名前 == '太郎'", true},
+		{"@名前 STARTS_WITH '太' AND @名前 LIKE '_郎'", true},
+		{`'it\'s' == "it's" AND 'a\tb' != 'a\\tb'`, true},
+		{"'a1b22' MATCHES `^a\\d+b\\d{2}$`", true},
+		{`'a1b22' MATCHES '^a\d+b\d{2}$' AND 'x.y' LIKE 'x\._' AND NOT ('xzy' MATCHES 'x\.y')`, true},
+		{"@age BETWEEN 18 AND 21", true},
+		{"@age BETWEEN 18 AND 21 EXCLUSIVE", false},
+		{"@age NOT BETWEEN 30 AND 40", true},
+		// AND/OR return the deciding operand, like JSONLogic
+		{"@zero AND @name", 0},
+		{"@zero OR @name", "John"},
+		{"'' OR []", []interface{}{}},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - ParseProgram() failed for %q. Errors: %v", i, tt.input, p.Errors())
+			continue
+		}
+
+		result, err := Evaluate(expression, data)
+		if err != nil {
+			t.Errorf("test[%d] - Evaluate() failed for %q: %v", i, tt.input, err)
+			continue
+		}
+
+		if !sameValue(result, tt.expected) {
+			t.Errorf("test[%d] - wrong result for %q. got=%#v, want=%#v", i, tt.input, result, tt.expected)
+		}
+	}
+}
+
+// sameValue reports whether two results are equal once every number, at any
+// depth, is converted to a float64. NaN equals NaN.
+func sameValue(a, b interface{}) bool {
+	a, b = normalizeNumbers(a), normalizeNumbers(b)
+	if x, ok := a.(float64); ok && math.IsNaN(x) {
+		y, ok := b.(float64)
+		return ok && math.IsNaN(y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func normalizeNumbers(v interface{}) interface{} {
+	if n, ok := asNumber(v); ok {
+		return n
+	}
+	switch val := v.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = normalizeNumbers(item)
+		}
+		return items
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(val))
+		for key, field := range val {
+			fields[key] = normalizeNumbers(field)
+		}
+		return fields
+	}
+	return v
+}
+
+func TestEvaluateDates(t *testing.T) {
+	evaluator := &Evaluator{Clock: func() time.Time {
+		return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	}}
+	data := map[string]interface{}{
+		"createdAt": "2025-02-20T09:30:00Z",
+		"renewedAt": time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		"expiresAt": float64(1767225600000), // 2026-01-01
+		"invalid":   "soon",
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"@createdAt > NOW() - 30d", true},
+		{"@createdAt > NOW() - 1w", false},
+		{"@renewedAt + 90d < NOW()", true},
+		{"NOW() - @renewedAt > 12w", true},
+		{"@expiresAt > NOW() AND @expiresAt == DATE('2026-01-01')", true},
+		{"@createdAt BETWEEN '2025-02-01' AND DATE('2025-02-28')", true},
+		{"DATE(@invalid)", nil},
+		{"@invalid > NOW()", false},
+		{"NOW() - DATE('2025-03-01') == 12h", true},
+		{"1h30m == 90m", true},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - ParseProgram() failed for %q. Errors: %v", i, tt.input, p.Errors())
+			continue
+		}
+
+		result, err := evaluator.Evaluate(expression, data)
+		if err != nil {
+			t.Errorf("test[%d] - Evaluate() failed for %q: %v", i, tt.input, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("test[%d] - wrong result for %q. got=%#v, want=%#v", i, tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestEvaluateShortCircuit(t *testing.T) {
+	p := NewParser(NewLexer("@age < 18 AND LOG(@age)"))
+	expression := p.ParseProgram()
+
+	var buf bytes.Buffer
+	evaluator := &Evaluator{Logger: log.New(&buf, "", 0)}
+	if _, err := evaluator.Evaluate(expression, map[string]interface{}{"age": 30}); err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected LOG() not to run, got output %q", buf.String())
+	}
+
+	p = NewParser(NewLexer("LOG(@age) > 18"))
+	expression = p.ParseProgram()
+	result, err := evaluator.Evaluate(expression, map[string]interface{}{"age": 30})
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if result != true || buf.String() != "30\n" {
+		t.Errorf("wrong LOG() behaviour. got result=%v output=%q", result, buf.String())
+	}
+}
+
+func TestEvaluatePatternCacheBounded(t *testing.T) {
+	p := NewParser(NewLexer("@value MATCHES @pattern"))
+	expression := p.ParseProgram()
+
+	// Patterns from the data must not grow the cache without limit
+	for i := 0; i < 2*patternCacheSize; i++ {
+		data := map[string]interface{}{"value": fmt.Sprintf("id-%d", i), "pattern": fmt.Sprintf("^id-%d$", i)}
+		result, err := Evaluate(expression, data)
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+		if result != true {
+			t.Fatalf("expected pattern %d to match, got %v", i, result)
+		}
+	}
+	if n := patternCache.len(); n > patternCacheSize {
+		t.Errorf("pattern cache holds %d patterns, want at most %d", n, patternCacheSize)
+	}
+}
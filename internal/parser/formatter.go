@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLineWidth is the column after which the formatter wraps AND/OR chains
+	MaxLineWidth = 80
+
+	// indentWidth is the number of spaces each continuation level is indented by
+	indentWidth = 4
+)
+
+// Format renders an expression as canonical REL: keywords uppercased, single
+// spaces around operators, minimal parentheses, and AND/OR chains that do not
+// fit in MaxLineWidth columns broken into one operand per line:
+//
+//	@age >= 18
+//	    AND @country IN ['IN', 'US']
+//	    AND (
+//	        @plan == 'pro'
+//	        OR @trial == true
+//	    )
+//
+// Comments, as returned by Parser.Comments, are written before the operand
+// they precede or, when they follow an operand on the same line, after it.
+// The result has no trailing newline.
+func Format(node Expression, comments []Comment) string {
+	f := &formatter{comments: comments}
+
+	var sb strings.Builder
+	for _, c := range f.takeLeadingComments(node) {
+		sb.WriteString(c.Text)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(f.format(node, 0, 0))
+
+	// Whatever is left follows the expression
+	end := node.Location().End
+	for _, c := range f.comments {
+		if c.Span.Start.Line == end.Line {
+			sb.WriteString(" ")
+		} else {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(c.Text)
+		end = c.Span.End
+	}
+	return sb.String()
+}
+
+// formatter holds the comments that have not been written yet
+type formatter struct {
+	comments []Comment
+}
+
+// format renders node starting at the given column. Lines after the first are
+// indented relative to indent, the indentation of the line node starts on.
+func (f *formatter) format(node Expression, column, indent int) string {
+	be, ok := node.(*BinaryExpression)
+	if !ok || !isLogicalOperator(be.Token.Type) {
+		return Print(node)
+	}
+
+	flat := Print(node)
+	if column+utf8.RuneCountInString(flat) <= MaxLineWidth && !f.hasCommentsWithin(node.Location()) {
+		return flat
+	}
+
+	operands := flattenChain(be)
+	continuation := indent + indentWidth
+	keyword := string(be.Token.Type)
+
+	var sb strings.Builder
+	for i, operand := range operands {
+		operandColumn := column
+		if i > 0 {
+			f.writeTrailingComments(&sb, operands[i-1].Location().End, operand.Location().Start)
+			sb.WriteString("\n")
+			for _, c := range f.takeLeadingComments(operand) {
+				sb.WriteString(pad(continuation))
+				sb.WriteString(c.Text)
+				sb.WriteString("\n")
+			}
+			sb.WriteString(pad(continuation))
+			sb.WriteString(keyword)
+			sb.WriteString(" ")
+			operandColumn = continuation + len(keyword) + 1
+		} else {
+			for _, c := range f.takeLeadingComments(operand) {
+				sb.WriteString(c.Text)
+				sb.WriteString("\n")
+				sb.WriteString(pad(column))
+			}
+		}
+
+		// Only the right-hand side of the chain can need parentheses at the same precedence
+		min := nodePrecedence(be)
+		if i > 0 {
+			min++
+		}
+		sb.WriteString(f.formatOperand(operand, needsParens(be, operand, min), operandColumn, continuation))
+	}
+	return sb.String()
+}
+
+// formatOperand renders one operand of a wrapped chain. A parenthesised
+// operand that does not fit on the line is opened and closed on lines of its own.
+func (f *formatter) formatOperand(operand Expression, parens bool, column, indent int) string {
+	if !parens {
+		return f.format(operand, column, indent)
+	}
+
+	flat := Print(operand)
+	if column+utf8.RuneCountInString(flat)+2 <= MaxLineWidth && !f.hasCommentsWithin(operand.Location()) {
+		return "(" + flat + ")"
+	}
+
+	inner := indent + indentWidth
+	// The wrapped operands line up with the first one inside the parentheses
+	return "(\n" + pad(inner) + f.format(operand, inner, indent) + "\n" + pad(indent) + ")"
+}
+
+// writeTrailingComments appends comments that sit on the same line as the end
+// of the previous operand, before the next operand starts
+func (f *formatter) writeTrailingComments(sb *strings.Builder, prevEnd, nextStart Position) {
+	for len(f.comments) > 0 {
+		c := f.comments[0]
+		if c.Span.Start.Offset >= nextStart.Offset || c.Span.Start.Line != prevEnd.Line {
+			return
+		}
+		sb.WriteString(" ")
+		sb.WriteString(c.Text)
+		f.comments = f.comments[1:]
+	}
+}
+
+// takeLeadingComments removes and returns the comments written before node.
+// Comments inside a node that will not be wrapped are hoisted in front of it
+// so they are never lost.
+func (f *formatter) takeLeadingComments(node Expression) []Comment {
+	limit := node.Location().Start.Offset
+	if be, ok := node.(*BinaryExpression); !ok || !isLogicalOperator(be.Token.Type) {
+		limit = node.Location().End.Offset
+	}
+
+	var taken []Comment
+	for len(f.comments) > 0 && f.comments[0].Span.Start.Offset < limit {
+		taken = append(taken, f.comments[0])
+		f.comments = f.comments[1:]
+	}
+	return taken
+}
+
+// hasCommentsWithin reports whether a pending comment lies inside span
+func (f *formatter) hasCommentsWithin(span Span) bool {
+	for _, c := range f.comments {
+		if c.Span.Start.Offset >= span.Start.Offset && c.Span.Start.Offset < span.End.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenChain collects the operands of a left-nested chain of the same
+// logical operator, e.g. ((a AND b) AND c) becomes [a, b, c]
+func flattenChain(be *BinaryExpression) []Expression {
+	if left, ok := be.Left.(*BinaryExpression); ok && left.Token.Type == be.Token.Type {
+		return append(flattenChain(left), be.Right)
+	}
+	return []Expression{be.Left, be.Right}
+}
+
+// isLogicalOperator reports whether a token type is AND or OR
+func isLogicalOperator(t TokenType) bool {
+	return t == AND || t == OR
+}
+
+func pad(n int) string {
+	return strings.Repeat(" ", n)
+}
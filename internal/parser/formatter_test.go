@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"@age>18 and @name=='John'", "@age > 18 AND @name == 'John'"},
+		{"((@a + @b)) * (@c)", "(@a + @b) * @c"},
+		{"@a = 1 or (@b AND @c)", "@a == 1 OR @b AND @c"},
+		{"not (@a > 1)", "NOT (@a > 1)"},
+		{"@role not in ['admin','moderator']", "@role NOT IN ['admin', 'moderator']"},
+		{"@a - (@b - 1)", "@a - (@b - 1)"},
+		{"@flag == TRUE", "@flag == true"},
+		{"log(@a)", "LOG(@a)"},
+		{"if @vip then (0.2) else if @member then 0.1 else 0", "IF @vip THEN 0.2 ELSE IF @member THEN 0.1 ELSE 0"},
+		{"any @cart as item : (item.price > 100) and item.qty>1", "ANY @cart AS item: item.price > 100 AND item.qty > 1"},
+		{"(all @xs as x: x) or @y", "(ALL @xs AS x: x) OR @y"},
+		{"reduce(@xs,(s,x)->s+x,0)", "REDUCE(@xs, (s, x) -> s + x, 0)"},
+		{"filter(@xs,(x)->x)", "FILTER(@xs, x -> x)"},
+		{"@a contains ('x') and @b in (@c ?? [])", "@a CONTAINS 'x' AND @b IN @c ?? []"},
+		{"@a matches ('x+') or @b like 'y%'", "@a MATCHES 'x+' OR @b LIKE 'y%'"},
+		{`@a matches '^x\.y$' and @b like '50\% %'`, `@a MATCHES '^x\\.y$' AND @b LIKE '50\\% %'`},
+		{"@createdAt>now()-30d and (@t) between date('2025-01-01') and '2025-02-01'", "@createdAt > NOW() - 30d AND @t BETWEEN DATE('2025-01-01') AND '2025-02-01'"},
+		{"@a > - 3 and @b == 0X1f or @c < 1_000", "@a > -3 AND @b == 0X1f OR @c < 1_000"},
+		{`@a == 'it\'s' or @b == "tab\tq\u0022" or @c matches ` + "`\\d+`", `@a == "it's" OR @b == 'tab\tq"' OR @c MATCHES ` + "`\\d+`"},
+		{"exists @a and @b is not missing", "EXISTS @a AND @b IS NOT MISSING"},
+		{"@a??(@b)??0", "@a ?? @b ?? 0"},
+		{"default(@a, 1 + 2)", "DEFAULT(@a, 1 + 2)"},
+		{"(@a ?? 1) * 2", "(@a ?? 1) * 2"},
+		{`@user["first-name"] == @items.0["price"]`, "@user['first-name'] == @items[0].price"},
+		{"@age between 18 and (65) exclusive", "@age BETWEEN 18 AND 65 EXCLUSIVE"},
+		{"@a IN [(1 + 2), 3, (-@b)]", "@a IN [1 + 2, 3, -@b]"},
+		{"max([(@x * 2), (if @y then 1 else 2)]) > 0", "MAX([@x * 2, IF @y THEN 1 ELSE 2]) > 0"},
+		{
+			"@subscription_status == 'active' AND @account_age_days > 30 AND @country IN ['IN', 'US', 'DE']",
+			"@subscription_status == 'active'\n" +
+				"    AND @account_age_days > 30\n" +
+				"    AND @country IN ['IN', 'US', 'DE']",
+		},
+		{
+			"@subscription_status == 'active' AND (@plan_name == 'professional' OR @trial_days_left > 0 OR @beta_program_member == true)",
+			"@subscription_status == 'active'\n" +
+				"    AND (\n" +
+				"        @plan_name == 'professional'\n" +
+				"        OR @trial_days_left > 0\n" +
+				"        OR @beta_program_member == true\n" +
+				"    )",
+		},
+		{
+			"// adults only\n@age > 18 // inclusive\nAND /* region */ @country == 'IN'",
+			"// adults only\n" +
+				"@age > 18 // inclusive\n" +
+				"    /* region */\n" +
+				"    AND @country == 'IN'",
+		},
+		{"@age > /* legal */ 18", "/* legal */\n@age > 18"},
+		{"@age > 18 // adults", "@age > 18 // adults"},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Fatalf("test[%d] - ParseProgram() failed: %v", i, p.Errors())
+		}
+
+		got := Format(expression, p.Comments())
+		if got != tt.expected {
+			t.Errorf("test[%d] - wrong format.\ngot:\n%s\nwant:\n%s", i, got, tt.expected)
+			continue
+		}
+
+		// Formatting must be idempotent
+		p = NewParser(NewLexer(got))
+		expression = p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - formatted output does not parse: %v", i, p.Errors())
+			continue
+		}
+		if again := Format(expression, p.Comments()); again != got {
+			t.Errorf("test[%d] - formatting is not idempotent.\nfirst:\n%s\nsecond:\n%s", i, got, again)
+		}
+	}
+}
+
+func TestFormatPreservesLogic(t *testing.T) {
+	tests := []string{
+		"@a IN [(1 + 2), 3]",
+		`@email MATCHES '^.+@corp\.com$' AND @name LIKE '50\% %'`,
+		"MAX([(@x * 2)]) > 10",
+		"MERGE([(@a == 1), (@b ?? 0), (NOT @c)], [IF @d THEN 1 ELSE 2]) == []",
+		"@role NOT IN ['admin', 'moderator'] OR (@a + @b) % 2 == 0",
+		"@createdAt > NOW() - 30d AND @t BETWEEN DATE('2025-01-01') AND '2025-02-01'",
+	}
+
+	for i, input := range tests {
+		p := NewParser(NewLexer(input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Fatalf("test[%d] - ParseProgram() failed: %v", i, p.Errors())
+		}
+		want, err := Transform(expression)
+		if err != nil {
+			t.Fatalf("test[%d] - Transform() failed: %v", i, err)
+		}
+
+		formatted := Format(expression, p.Comments())
+		p = NewParser(NewLexer(formatted))
+		expression = p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - %q does not parse: %v", i, formatted, p.Errors())
+			continue
+		}
+		got, err := Transform(expression)
+		if err != nil {
+			t.Errorf("test[%d] - Transform(%q) failed: %v", i, formatted, err)
+			continue
+		}
+
+		wantJSON, _ := json.Marshal(want)
+		gotJSON, _ := json.Marshal(got)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("test[%d] - %q compiles differently.\ngot:  %s\nwant: %s", i, formatted, gotJSON, wantJSON)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// ValueType names the kind of value a function argument must have
+type ValueType string
+
+const (
+	TYPE_ANY     ValueType = "any"
+	TYPE_NUMBER  ValueType = "number"
+	TYPE_STRING  ValueType = "string"
+	TYPE_BOOLEAN ValueType = "boolean"
+	TYPE_ARRAY   ValueType = "array"
+)
+
+// Function describes a function that can be called from REL
+type Function struct {
+	// Name is the uppercase name the function is called by
+	Name string
+
+	// MinArgs and MaxArgs bound the number of arguments; a negative MaxArgs
+	// means any number of arguments is accepted
+	MinArgs int
+	MaxArgs int
+
+	// Params optionally gives the type of each argument; the last entry
+	// applies to any further arguments. Arguments whose type is known while
+	// parsing, such as literals, are checked against it.
+	Params []ValueType
+
+	// Emit builds the JSONLogic for a call from its transformed arguments
+	Emit func(args []interface{}) (interface{}, error)
+
+	// Eval computes the result of a call from its evaluated arguments
+	Eval func(args []interface{}) (interface{}, error)
+
+	// literal checks a string literal passed as the last argument while
+	// parsing, e.g. the pattern of MATCHES, and reports errors as literalCode
+	literal     func(string) error
+	literalCode DiagnosticCode
+
+	// pattern marks an operator whose right operand is a pattern, where
+	// escapes that strings do not define, like \d or \%, are kept as written
+	pattern bool
+}
+
+// checkArity returns a description of the problem when n arguments do not fit
+// the function's signature, or "" when they do
+func (f *Function) checkArity(n int) string {
+	switch {
+	case f.MinArgs == f.MaxArgs && n != f.MinArgs:
+		return fmt.Sprintf("%s expects %s, got %d", f.Name, pluralArguments(f.MinArgs), n)
+	case n < f.MinArgs:
+		return fmt.Sprintf("%s expects at least %s, got %d", f.Name, pluralArguments(f.MinArgs), n)
+	case f.MaxArgs >= 0 && n > f.MaxArgs:
+		return fmt.Sprintf("%s expects at most %s, got %d", f.Name, pluralArguments(f.MaxArgs), n)
+	default:
+		return ""
+	}
+}
+
+// paramType returns the declared type of the argument at index i
+func (f *Function) paramType(i int) ValueType {
+	switch {
+	case len(f.Params) == 0:
+		return TYPE_ANY
+	case i < len(f.Params):
+		return f.Params[i]
+	default:
+		return f.Params[len(f.Params)-1]
+	}
+}
+
+// EmitOperation returns an emitter producing {operator: [args...]}, the shape
+// of every JSONLogic operation
+func EmitOperation(operator string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		return JSONLogic{operator: args}, nil
+	}
+}
+
+func pluralArguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// builtinFunctions are the functions available to every REL expression
+var builtinFunctions = map[string]*Function{
+	"LOG": {
+		Name:    "LOG",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Emit:    EmitOperation("log"),
+		// The evaluator handles LOG itself so it can use its Logger
+	},
+	"DATE": {
+		Name:        "DATE",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Emit:        EmitOperation("date"),
+		Eval:        func(args []interface{}) (interface{}, error) { return toDate(args[0]), nil },
+		literal:     checkDate,
+		literalCode: E_INVALID_DATE,
+	},
+	"NOW": {
+		Name:    "NOW",
+		MinArgs: 0,
+		MaxArgs: 0,
+		Emit:    EmitOperation("now"),
+		// The evaluator handles NOW so it can use its Clock
+	},
+	"MAX": {
+		Name:    "MAX",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    emitExtremum("max"),
+		Eval:    evalExtremum(math.Max),
+	},
+	"MIN": {
+		Name:    "MIN",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    emitExtremum("min"),
+		Eval:    evalExtremum(math.Min),
+	},
+	"SUM": {
+		Name:    "SUM",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    emitSum,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return sum(aggregateValues(args)), nil
+		},
+	},
+	"MERGE": {
+		Name:    "MERGE",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    EmitOperation("merge"),
+		Eval: func(args []interface{}) (interface{}, error) {
+			return merge(args), nil
+		},
+	},
+	"CAT": {
+		Name:    "CAT",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    EmitOperation("cat"),
+		Eval: func(args []interface{}) (interface{}, error) {
+			var sb strings.Builder
+			for _, arg := range args {
+				sb.WriteString(toString(arg))
+			}
+			return sb.String(), nil
+		},
+	},
+	"SUBSTR": {
+		Name:    "SUBSTR",
+		MinArgs: 2,
+		MaxArgs: 3,
+		Params:  []ValueType{TYPE_ANY, TYPE_NUMBER},
+		Emit:    EmitOperation("substr"),
+		Eval: func(args []interface{}) (interface{}, error) {
+			return substr(args), nil
+		},
+	},
+	"AVG": {
+		Name:    "AVG",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Emit:    emitAverage,
+		Eval: func(args []interface{}) (interface{}, error) {
+			values := aggregateValues(args)
+			return sum(values) / float64(len(values)), nil
+		},
+	},
+}
+
+// resolveFunction returns the function a call refers to: the one the parser
+// resolved it to, or the builtin of the same name for calls built by hand
+func resolveFunction(fc *FunctionCall) (*Function, error) {
+	if fc.Definition != nil {
+		return fc.Definition, nil
+	}
+	if fn, ok := builtinFunctions[fc.Function]; ok && !fc.Infix {
+		return fn, nil
+	}
+	if fn, ok := builtinOperators[fc.Function]; ok && fc.Infix {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unsupported function: %s", fc.Function)
+}
+
+// staticType returns the type of an expression when it is known without
+// evaluating it, or TYPE_ANY
+func staticType(exp Expression) ValueType {
+	switch n := exp.(type) {
+	case *Literal:
+		switch n.Token.Type {
+		case NUMBER, DURATION:
+			return TYPE_NUMBER
+		case STRING:
+			return TYPE_STRING
+		case TRUE, FALSE:
+			return TYPE_BOOLEAN
+		}
+	case *ArrayLiteral:
+		return TYPE_ARRAY
+	case *BinaryExpression:
+		switch precedences[n.Token.Type] {
+		case SUM, PRODUCT:
+			return TYPE_NUMBER
+		case COMPARISON:
+			return TYPE_BOOLEAN
+		}
+	case *BetweenExpression, *QuantifierExpression:
+		return TYPE_BOOLEAN
+	case *CollectionExpression:
+		if n.Token.Type != REDUCE {
+			return TYPE_ARRAY
+		}
+	case *FunctionCall:
+		// Dates are numbers of epoch milliseconds
+		if isDate(n) {
+			return TYPE_NUMBER
+		}
+	}
+	return TYPE_ANY
+}
+
+// Aggregates accept either a list of values, MAX(@a, @b, 3), or a single
+// array, MAX(@scores) or MAX([1, 2, 3]). An array literal is spread into the
+// argument list; an array held in a variable is folded with JSONLogic's
+// reduce, since max, min and + cannot spread their arguments.
+
+// emitExtremum emits {"max": [...]} or {"min": [...]}
+func emitExtremum(operator string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		array, ok := arrayArgument(args)
+		if !ok {
+			return JSONLogic{operator: spreadArguments(args)}, nil
+		}
+
+		// The first element seeds the accumulator, so an empty array gives null
+		current, accumulator := JSONLogic{"var": "current"}, JSONLogic{"var": "accumulator"}
+		return JSONLogic{"reduce": []interface{}{
+			array,
+			JSONLogic{"if": []interface{}{
+				JSONLogic{"==": []interface{}{accumulator, nil}},
+				current,
+				JSONLogic{operator: []interface{}{current, accumulator}},
+			}},
+			nil,
+		}}, nil
+	}
+}
+
+// emitSum emits {"+": [...]}
+func emitSum(args []interface{}) (interface{}, error) {
+	if array, ok := arrayArgument(args); ok {
+		return reduceSum(array), nil
+	}
+	return JSONLogic{"+": spreadArguments(args)}, nil
+}
+
+// emitAverage emits the sum of the values divided by their count
+func emitAverage(args []interface{}) (interface{}, error) {
+	if array, ok := arrayArgument(args); ok {
+		count := JSONLogic{"reduce": []interface{}{
+			array,
+			JSONLogic{"+": []interface{}{JSONLogic{"var": "accumulator"}, 1}},
+			0,
+		}}
+		return JSONLogic{"/": []interface{}{reduceSum(array), count}}, nil
+	}
+
+	values := spreadArguments(args)
+	return JSONLogic{"/": []interface{}{JSONLogic{"+": values}, float64(len(values))}}, nil
+}
+
+// reduceSum emits a reduce that adds up the elements of array
+func reduceSum(array interface{}) JSONLogic {
+	return JSONLogic{"reduce": []interface{}{
+		array,
+		JSONLogic{"+": []interface{}{JSONLogic{"var": "current"}, JSONLogic{"var": "accumulator"}}},
+		0,
+	}}
+}
+
+// arrayArgument returns the only argument when it is a variable reference or
+// a filter, map or merge, whose value is treated as the array to aggregate
+func arrayArgument(args []interface{}) (interface{}, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	logic, ok := args[0].(JSONLogic)
+	if !ok || len(logic) != 1 {
+		return nil, false
+	}
+	for _, operator := range []string{"var", "filter", "map", "merge"} {
+		if logic[operator] != nil {
+			return logic, true
+		}
+	}
+	return nil, false
+}
+
+// spreadArguments returns the elements of a single array literal argument,
+// or the arguments unchanged
+func spreadArguments(args []interface{}) []interface{} {
+	if len(args) == 1 {
+		if elements, ok := args[0].([]interface{}); ok {
+			return elements
+		}
+	}
+	return args
+}
+
+// aggregateValues returns the values an aggregate operates on: the elements
+// of a single array argument, or the arguments themselves
+func aggregateValues(args []interface{}) []interface{} {
+	if len(args) == 1 {
+		if elements, ok := asArray(args[0]); ok {
+			return elements
+		}
+	}
+	return args
+}
+
+// evalExtremum computes MAX or MIN like JavaScript's Math.max and Math.min:
+// a value that is not a number makes the result NaN
+func evalExtremum(pick func(a, b float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		values := aggregateValues(args)
+		if len(values) == 0 {
+			return nil, nil
+		}
+
+		result := toNumber(values[0])
+		for _, value := range values[1:] {
+			result = pick(result, toNumber(value))
+		}
+		return result, nil
+	}
+}
+
+func sum(values []interface{}) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += toNumber(value)
+	}
+	return total
+}
+
+// merge concatenates its arguments into one array like JSONLogic's merge:
+// arrays are flattened one level, other values are appended as they are
+func merge(args []interface{}) []interface{} {
+	merged := []interface{}{}
+	for _, arg := range args {
+		if items, ok := asArray(arg); ok {
+			merged = append(merged, items...)
+		} else {
+			merged = append(merged, arg)
+		}
+	}
+	return merged
+}
+
+// substr implements SUBSTR(value, start[, length]) like JSONLogic's substr,
+// counting UTF-16 code units as JavaScript does: a negative start counts from
+// the end of the string, and a negative length leaves that many units off
+// the end
+func substr(args []interface{}) string {
+	chars := utf16.Encode([]rune(toString(args[0])))
+	start := toInteger(args[1])
+	if start < 0 {
+		start = max(len(chars)+start, 0)
+	}
+	start = min(start, len(chars))
+
+	end := len(chars)
+	if len(args) > 2 {
+		if length := toInteger(args[2]); length < 0 {
+			end = max(end+length, start)
+		} else {
+			end = min(start+length, end)
+		}
+	}
+	return string(utf16.Decode(chars[start:end]))
+}
+
+// toInteger converts a value to an integer like JavaScript's ToIntegerOrInfinity,
+// clamping infinities so the result can be used as a string index
+func toInteger(v interface{}) int {
+	n := toNumber(v)
+	if math.IsNaN(n) {
+		return 0
+	}
+	return int(math.Max(math.Min(math.Trunc(n), math.MaxInt32), math.MinInt32))
+}
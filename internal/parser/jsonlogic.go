@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // JSONLogic represents a JSON Logic compatible structure
@@ -27,12 +29,26 @@ func Transform(node Expression) (interface{}, error) {
 		return transformArrayLiteral(n)
 	case *FunctionCall:
 		return transformFunctionCall(n)
+	case *BetweenExpression:
+		return transformBetweenExpression(n)
+	case *DefaultExpression:
+		return transformDefaultExpression(n)
+	case *MissingExpression:
+		return transformMissingExpression(n)
+	case *ConditionalExpression:
+		return transformConditionalExpression(n)
+	case *QuantifierExpression:
+		return transformQuantifierExpression(n)
+	case *CollectionExpression:
+		return transformCollectionExpression(n)
+	case *BadExpression:
+		return nil, fmt.Errorf("cannot transform invalid expression at %s", n.Span.Start)
 	default:
 		return nil, fmt.Errorf("unsupported node type: %T", n)
 	}
 }
 
-// transformBinaryExpression handles binary operations (AND, OR, comparisons)
+// transformBinaryExpression handles binary operations (AND, OR, comparisons, arithmetic)
 func transformBinaryExpression(be *BinaryExpression) (JSONLogic, error) {
 	left, err := Transform(be.Left)
 	if err != nil {
@@ -44,16 +60,28 @@ func transformBinaryExpression(be *BinaryExpression) (JSONLogic, error) {
 		return nil, err
 	}
 
+	switch be.Token.Type {
+	case CONTAINS:
+		// JSONLogic's in takes the substring or element first
+		return JSONLogic{"in": []interface{}{right, left}}, nil
+	case STARTS_WITH, ENDS_WITH:
+		return transformAffixExpression(be, left)
+	}
+
 	// Map operators to JSONLogic format
 	switch be.Operator {
 	case "AND":
 		return JSONLogic{"and": []interface{}{left, right}}, nil
 	case "OR":
 		return JSONLogic{"or": []interface{}{left, right}}, nil
-	case "=", "==", "===":
+	case "=", "==":
 		return JSONLogic{"==": []interface{}{left, right}}, nil
-	case "!=", "!==":
+	case "===":
+		return JSONLogic{"===": []interface{}{left, right}}, nil
+	case "!=":
 		return JSONLogic{"!=": []interface{}{left, right}}, nil
+	case "!==":
+		return JSONLogic{"!==": []interface{}{left, right}}, nil
 	case ">":
 		return JSONLogic{">": []interface{}{left, right}}, nil
 	case "<":
@@ -64,41 +92,243 @@ func transformBinaryExpression(be *BinaryExpression) (JSONLogic, error) {
 		return JSONLogic{"<=": []interface{}{left, right}}, nil
 	case "IN":
 		return JSONLogic{"in": []interface{}{left, right}}, nil
+	case "+", "-", "*", "/", "%":
+		return JSONLogic{be.Operator: []interface{}{left, right}}, nil
 	default:
 		return nil, fmt.Errorf("unsupported binary operator: %s", be.Operator)
 	}
 }
 
-// transformUnaryExpression handles unary operations (NOT, !)
+// transformAffixExpression lowers STARTS_WITH and ENDS_WITH to substr
+// comparisons, {"==": [{"substr": [value, 0, n]}, prefix]} and
+// {"==": [{"substr": [value, -n]}, suffix]}, where n is the length of the
+// prefix or suffix in UTF-16 code units, which is how JavaScript counts
+func transformAffixExpression(be *BinaryExpression, value interface{}) (JSONLogic, error) {
+	affix, ok := stringLiteral(be.Right)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a string literal", be.Token.Type)
+	}
+
+	n := float64(utf16Length(affix))
+	substr := []interface{}{value, 0.0, n}
+	// A start of -0 would take the whole string, so an empty suffix is
+	// checked like an empty prefix
+	if be.Token.Type == ENDS_WITH && n > 0 {
+		substr = []interface{}{value, -n}
+	}
+	return JSONLogic{"==": []interface{}{JSONLogic{"substr": substr}, affix}}, nil
+}
+
+// transformBetweenExpression emits JSONLogic's three-argument comparison,
+// {"<=": [lower, value, upper]}, or "<" for an exclusive range
+func transformBetweenExpression(be *BetweenExpression) (JSONLogic, error) {
+	args := make([]interface{}, 3)
+	for i, operand := range []Expression{be.Lower, be.Value, be.Upper} {
+		transformed, err := Transform(operand)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = transformed
+	}
+
+	if be.Exclusive {
+		return JSONLogic{"<": args}, nil
+	}
+	return JSONLogic{"<=": args}, nil
+}
+
+// transformDefaultExpression emits JSONLogic's var default for a single
+// fallback, {"var": ["age", 0]}. Longer chains test each variable with
+// missing, keeping the var default for the last pair:
+//
+//	@a ?? @b ?? 0  =>  {"if": [{"!": [{"missing": ["a"]}]}, {"var": "a"}, {"var": ["b", 0]}]}
+func transformDefaultExpression(de *DefaultExpression) (interface{}, error) {
+	n := len(de.Operands)
+	if n < 2 {
+		return nil, fmt.Errorf("default needs at least 2 operands, got %d", n)
+	}
+
+	paths := make([]string, n-1)
+	for i, operand := range de.Operands[:n-1] {
+		v, ok := operand.(*Variable)
+		if !ok {
+			return nil, fmt.Errorf("only a variable can have a default value, got %T", operand)
+		}
+		paths[i] = strings.Join(v.Path, ".")
+	}
+
+	fallback, err := Transform(de.Operands[n-1])
+	if err != nil {
+		return nil, err
+	}
+	last := JSONLogic{"var": []interface{}{paths[n-2], fallback}}
+	if n == 2 {
+		return last, nil
+	}
+
+	args := make([]interface{}, 0, 2*(n-2)+1)
+	for _, path := range paths[:n-2] {
+		present := JSONLogic{"!": []interface{}{JSONLogic{"missing": []interface{}{path}}}}
+		args = append(args, present, JSONLogic{"var": path})
+	}
+	return JSONLogic{"if": append(args, last)}, nil
+}
+
+// transformMissingExpression emits {"missing": [...]} or
+// {"missing_some": [need, [...]]}, negated for EXISTS and IS NOT MISSING
+func transformMissingExpression(me *MissingExpression) (interface{}, error) {
+	paths := make([]interface{}, len(me.Variables))
+	for i, v := range me.Variables {
+		paths[i] = strings.Join(v.Path, ".")
+	}
+
+	var missing JSONLogic
+	if me.Need != nil {
+		need, err := Transform(me.Need)
+		if err != nil {
+			return nil, err
+		}
+		missing = JSONLogic{"missing_some": []interface{}{need, paths}}
+	} else {
+		missing = JSONLogic{"missing": paths}
+	}
+
+	if me.Negated {
+		return JSONLogic{"!": []interface{}{missing}}, nil
+	}
+	return missing, nil
+}
+
+// transformConditionalExpression emits JSONLogic's variadic if:
+// {"if": [cond1, result1, cond2, result2, ..., else]}
+func transformConditionalExpression(ce *ConditionalExpression) (JSONLogic, error) {
+	args := make([]interface{}, 0, 2*len(ce.Branches)+1)
+	for _, branch := range ce.Branches {
+		for _, operand := range []Expression{branch.Condition, branch.Result} {
+			transformed, err := Transform(operand)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, transformed)
+		}
+	}
+
+	if ce.Else != nil {
+		transformed, err := Transform(ce.Else)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, transformed)
+	}
+	return JSONLogic{"if": args}, nil
+}
+
+// quantifierOperators maps each quantifier to the JSONLogic operator it compiles to
+var quantifierOperators = map[TokenType]string{
+	ANY:  "some",
+	ALL:  "all",
+	NONE: "none",
+}
+
+// transformQuantifierExpression emits {"some": [collection, body]}. JSONLogic
+// evaluates the body with each element as the data, so element references
+// such as item.price become {"var": "price"}.
+func transformQuantifierExpression(qe *QuantifierExpression) (JSONLogic, error) {
+	collection, err := Transform(qe.Collection)
+	if err != nil {
+		return nil, err
+	}
+	body, err := Transform(qe.Body)
+	if err != nil {
+		return nil, err
+	}
+	return JSONLogic{quantifierOperators[qe.Token.Type]: []interface{}{collection, body}}, nil
+}
+
+// transformCollectionExpression emits {"filter": [collection, body]},
+// {"map": [...]} or {"reduce": [collection, body, initial]}. Inside reduce
+// the element and accumulator are read from the current and accumulator
+// keys, which the parser already put at the start of their paths.
+func transformCollectionExpression(ce *CollectionExpression) (JSONLogic, error) {
+	operands := []Expression{ce.Collection, ce.Body}
+	if ce.Initial != nil {
+		operands = append(operands, ce.Initial)
+	}
+
+	args := make([]interface{}, len(operands))
+	for i, operand := range operands {
+		transformed, err := Transform(operand)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = transformed
+	}
+	return JSONLogic{strings.ToLower(string(ce.Token.Type)): args}, nil
+}
+
+// transformUnaryExpression handles unary operations (NOT, !, -)
 func transformUnaryExpression(ue *UnaryExpression) (JSONLogic, error) {
 	right, err := Transform(ue.Right)
 	if err != nil {
 		return nil, err
 	}
 
+	// JSONLogic's "-" with a single argument negates it
+	if ue.Operator == "-" {
+		return JSONLogic{"-": []interface{}{right}}, nil
+	}
+
 	// Both '!' and 'NOT' are mapped to the same JSONLogic operator
 	// Wrap the operand in an array as per JSONLogic format
 	return JSONLogic{"!": []interface{}{right}}, nil
 }
 
-// transformVariable handles variable references
+// transformVariable handles variable references; nested paths become
+// JSONLogic's dotted form, e.g. @items[0].price is "items.0.price"
 func transformVariable(v *Variable) (JSONLogic, error) {
-	// Remove the @ prefix for JSONLogic
-	name := v.Name[1:]
-	return JSONLogic{"var": name}, nil
+	return JSONLogic{"var": strings.Join(v.Path, ".")}, nil
 }
 
-// transformLiteral handles literal values (numbers, strings)
+// transformLiteral handles literal values (numbers, strings, booleans, null),
+// which the parser has already converted to Go values
 func transformLiteral(l *Literal) (interface{}, error) {
-	// For numbers, convert string to float64
-	if l.Token.Type == NUMBER {
-		if num, err := strconv.ParseFloat(l.Value.(string), 64); err == nil {
-			return num, nil
+	if l.Token.Type == NUMBER && l.Value == nil {
+		return nil, fmt.Errorf("invalid number %s", l.Token.Literal)
+	}
+	return l.Value, nil
+}
+
+// UseNumber returns logic with every number replaced by a json.Number, for
+// callers that process JSON decoded with json.Decoder.UseNumber
+func UseNumber(logic interface{}) interface{} {
+	switch v := logic.(type) {
+	case JSONLogic:
+		return JSONLogic(useNumberInMap(v))
+	case map[string]interface{}:
+		return useNumberInMap(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = UseNumber(item)
+		}
+		return items
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case float64:
+		if encoded, err := json.Marshal(v); err == nil {
+			return json.Number(encoded)
 		}
 	}
+	return logic
+}
 
-	// For strings and other literals, use as-is
-	return l.Value, nil
+// useNumberInMap applies UseNumber to the values of an operation
+func useNumberInMap(m map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		converted[key] = UseNumber(value)
+	}
+	return converted
 }
 
 // transformArrayLiteral handles array literals
@@ -115,8 +345,13 @@ func transformArrayLiteral(al *ArrayLiteral) (interface{}, error) {
 	return elements, nil
 }
 
-// transformFunctionCall handles function calls (LOG)
-func transformFunctionCall(fc *FunctionCall) (JSONLogic, error) {
+// transformFunctionCall emits a call through its function's emitter
+func transformFunctionCall(fc *FunctionCall) (interface{}, error) {
+	fn, err := resolveFunction(fc)
+	if err != nil {
+		return nil, err
+	}
+
 	args := make([]interface{}, len(fc.Arguments))
 	for i, arg := range fc.Arguments {
 		transformed, err := Transform(arg)
@@ -126,10 +361,5 @@ func transformFunctionCall(fc *FunctionCall) (JSONLogic, error) {
 		args[i] = transformed
 	}
 
-	switch fc.Function {
-	case "LOG":
-		return JSONLogic{"log": args}, nil
-	default:
-		return nil, fmt.Errorf("unsupported function: %s", fc.Function)
-	}
+	return fn.Emit(args)
 }
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -9,10 +12,21 @@ type Lexer struct {
 	position     int    // current position in input (points to current char)
 	nextPosition int    // next position in input (after current char)
 	ch           rune   // current char under examination
+	line         int    // line of the current char (1-based)
+	column       int    // column of the current char (1-based)
+	diagnostics  []Diagnostic
+	comments     []Comment
+}
+
+// Comment is a '//' or '/* */' comment found in the source. Comments are not
+// part of the AST; they are kept so tools like the formatter can preserve them.
+type Comment struct {
+	Text string // the comment including its delimiters
+	Span Span
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -21,27 +35,101 @@ func isLetter(ch rune) bool {
 	return unicode.IsLetter(ch) || ch == '_'
 }
 
+// isDigit reports whether ch is an ASCII digit; other scripts' digits can
+// appear in names but not in numbers
 func isDigit(ch rune) bool {
-	return unicode.IsDigit(ch)
+	return '0' <= ch && ch <= '9'
 }
 
-// readChar reads the next character and advances our positions in the input.
+// readChar decodes the next UTF-8 character and advances our positions in the
+// input. Columns count characters, not bytes.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	size := 1
 	if l.nextPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = rune(l.input[l.nextPosition])
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.nextPosition:])
+	}
+
+	// Stop advancing once we are past the end so offsets never exceed the input
+	if l.nextPosition <= len(l.input) {
+		l.position = l.nextPosition
+		l.nextPosition += size
+		l.column++
+	}
+
+	if l.ch == utf8.RuneError && size == 1 {
+		l.reportInvalidUTF8()
 	}
-	l.position = l.nextPosition
-	l.nextPosition++
+}
+
+// reportInvalidUTF8 records an error for the byte at the current position,
+// which does not start a valid UTF-8 sequence. Lexing continues with U+FFFD
+// in its place.
+func (l *Lexer) reportInvalidUTF8() {
+	start := l.currentPosition()
+	end := Position{Offset: start.Offset + 1, Line: start.Line, Column: start.Column + 1}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Code:     E_INVALID_UTF8,
+		Message:  fmt.Sprintf("invalid UTF-8 byte 0x%02x", l.input[start.Offset]),
+		Span:     Span{Start: start, End: end},
+	})
+}
+
+// Comments returns the comments skipped so far, in source order.
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+// addComment records the comment between start and the current char.
+func (l *Lexer) addComment(start Position) {
+	end := l.currentPosition()
+	l.comments = append(l.comments, Comment{
+		Text: strings.TrimRight(l.input[start.Offset:end.Offset], " \t\r"),
+		Span: Span{Start: start, End: end},
+	})
+}
+
+// takeDiagnostics returns the lexical errors found since the last call and clears them.
+func (l *Lexer) takeDiagnostics() []Diagnostic {
+	diagnostics := l.diagnostics
+	l.diagnostics = nil
+	return diagnostics
+}
+
+// addError records a lexical error covering the source between start and the current char.
+func (l *Lexer) addError(start Position, code DiagnosticCode, msg string) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  msg,
+		Span:     Span{Start: start, End: l.currentPosition()},
+	})
+}
+
+// currentPosition returns the source position of the current char.
+func (l *Lexer) currentPosition() Position {
+	return Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
 // peekChar returns the next character without advancing the position.
-func (l *Lexer) peekChar() byte {
-	if l.nextPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	return l.charAt(l.nextPosition)
+}
+
+// charAt decodes the character starting at offset, or returns 0 past the end.
+func (l *Lexer) charAt(offset int) rune {
+	if offset >= len(l.input) {
 		return 0 // ASCII for NULL, signifies EOF
 	}
-	return l.input[l.nextPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[offset:])
+	return ch
 }
 
 // skipWhitespaceAndComments advances past whitespace and '//' comments.
@@ -51,29 +139,38 @@ func (l *Lexer) skipWhitespaceAndComments() {
 			l.readChar()
 		} else if l.ch == '/' && l.peekChar() == '/' {
 			// single-line comment
+			start := l.currentPosition()
 			for l.ch != '\n' && l.ch != 0 {
 				l.readChar()
 			}
+			l.addComment(start)
 		} else if l.ch == '/' && l.peekChar() == '*' {
 			// block comment
+			start := l.currentPosition()
 			l.readChar()
 			l.readChar()
 			for !(l.ch == '*' && l.peekChar() == '/') && l.ch != 0 {
 				l.readChar()
 			}
+			if l.ch == 0 {
+				l.addError(start, E_UNTERMINATED_COMMENT, "unterminated block comment")
+				break
+			}
 			// consume '*/'
 			l.readChar()
 			l.readChar()
+			l.addComment(start)
 		} else {
 			break
 		}
 	}
 }
 
-// readIdentifier reads in an identifier (or keyword).
+// readIdentifier reads in an identifier (or keyword): a letter followed by
+// letters, digits and combining marks.
 func (l *Lexer) readIdentifier() string {
 	start := l.position
-	for isLetter(l.ch) {
+	for isNameChar(l.ch) {
 		l.readChar()
 	}
 	raw := l.input[start:l.position]
@@ -81,59 +178,207 @@ func (l *Lexer) readIdentifier() string {
 	return raw
 }
 
-// readVariable reads in a variable prefixed by '@'.
+// readVariable reads in a variable prefixed by '@': a name followed by any
+// number of property accesses (.city), array indices ([0]) and quoted keys
+// (["first-name"]).
 func (l *Lexer) readVariable() string {
 	start := l.position
 	l.readChar() // consume '@'
-	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' {
-		l.readChar()
+	if !isNameChar(l.ch) {
+		l.addError(l.currentPosition(), E_INVALID_VARIABLE, "expected a variable name after '@'")
+		return l.input[start:l.position]
 	}
+	l.readName()
+	l.readPath()
 	return l.input[start:l.position]
 }
 
+// readPath reads any number of property accesses, array indices and quoted
+// keys following a name.
+func (l *Lexer) readPath() {
+	for {
+		next := l.peekChar()
+		switch {
+		case l.ch == '.' && isNameChar(next):
+			l.readChar() // consume '.'
+			l.readName()
+		case l.ch == '[' && (isDigit(next) || next == '\'' || next == '"'):
+			l.readIndex()
+		default:
+			return
+		}
+	}
+}
+
+// isNameChar reports whether ch can appear in a variable name or path
+// segment, e.g. @größe or @名前. Combining marks are allowed so names in
+// decomposed form lex as one token.
+func isNameChar(ch rune) bool {
+	return isLetter(ch) || unicode.IsDigit(ch) || unicode.In(ch, unicode.Mn, unicode.Mc)
+}
+
+// readName reads one segment of a variable path.
+func (l *Lexer) readName() {
+	for isNameChar(l.ch) {
+		l.readChar()
+	}
+}
+
+// readIndex reads a bracketed array index or quoted key of a variable path.
+func (l *Lexer) readIndex() {
+	start := l.currentPosition()
+	l.readChar() // consume '['
+
+	if isDigit(l.ch) {
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	} else {
+		quote := l.ch
+		keyStart := l.nextPosition
+		l.readChar() // consume the opening quote
+		for l.ch != quote && l.ch != 0 {
+			l.readChar()
+		}
+		if l.ch != quote {
+			l.addError(start, E_UNTERMINATED_STRING, "unterminated key in variable path")
+			return
+		}
+		key := l.input[keyStart:l.position]
+		l.readChar() // consume the closing quote
+
+		// JSONLogic separates the keys of a path with dots
+		if key == "" || strings.Contains(key, ".") {
+			l.addError(start, E_INVALID_VARIABLE, fmt.Sprintf("key %q cannot be used in a variable path", key))
+		}
+	}
+
+	if l.ch != ']' {
+		l.addError(start, E_INVALID_VARIABLE, "expected ']' in variable path")
+		return
+	}
+	l.readChar() // consume ']'
+}
+
 // readNumber reads in a contiguous sequence of digits.
 func (l *Lexer) readNumber() string {
 	start := l.position
-	dotCount := 0
-	for isDigit(l.ch) || (l.ch == '.' && dotCount == 0) {
-		if l.ch == '.' {
-			dotCount++
+	if l.ch == '0' && (l.peekChar() == 'x' || l.peekChar() == 'X') {
+		// Any letters after 0x belong to the literal, so 0x1G is one invalid number
+		l.readChar()
+		l.readChar()
+		for isLetter(l.ch) || isDigit(l.ch) {
+			l.readChar()
 		}
+		return l.input[start:l.position]
+	}
+
+	l.readDigits()
+	if l.ch == '.' {
 		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if next == '+' || next == '-' {
+			next = l.charAt(l.nextPosition + 1)
+		}
+		if isDigit(next) {
+			l.readChar() // e
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
 	}
 	return l.input[start:l.position]
 }
 
-// readString reads a quoted string literal, including delimiters.
-func (l *Lexer) readString() string {
-	quote := l.ch // store the opening quote type (' or ")
+// readDigits reads decimal digits and the underscores separating them
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// readDurationUnits reads the rest of a duration literal after its first
+// number; the parser checks the units.
+func (l *Lexer) readDurationUnits() string {
+	start := l.position
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' {
+		l.readChar()
+	}
+	return l.input[start:l.position]
+}
+
+// readString reads a quoted or raw string literal, including delimiters, and
+// reports whether it was closed. Escapes are checked here, where their
+// positions are known; the parser decodes them.
+func (l *Lexer) readString() (string, bool) {
+	quote := l.ch // store the opening quote type (', " or `)
 	start := l.position
 	l.readChar() // move past opening quote
 
 	for l.ch != quote && l.ch != 0 {
-		if l.ch == '\\' {
-			l.readChar()
-			if l.ch == quote || l.ch == '\\' {
-				l.readChar()
-				continue
-			}
+		if l.ch == '\\' && quote != '`' {
+			l.readEscape()
+			continue
 		}
 		l.readChar()
 	}
 
-	if l.ch == quote {
-		l.readChar() // move past closing quote
+	if l.ch != quote {
+		return l.input[start:l.position], false
 	}
+	l.readChar() // move past closing quote
 
 	// Return the full string including quotes
-	return l.input[start:l.position]
+	return l.input[start:l.position], true
 }
 
-func (l *Lexer) NextToken() Token {
-	var token Token
+// readEscape reads an escape sequence in a string, reporting it if invalid.
+func (l *Lexer) readEscape() {
+	start := l.currentPosition()
+	_, size, err := decodeEscape(l.input[l.nextPosition:])
+	end := l.nextPosition + size
+
+	l.readChar() // consume '\'
+	for l.position < end && l.ch != 0 {
+		l.readChar()
+	}
+	if err != nil {
+		l.addError(start, E_INVALID_ESCAPE, err.Error())
+	}
+}
 
+// NextToken returns the next token in the input, annotated with its source span.
+func (l *Lexer) NextToken() Token {
 	l.skipWhitespaceAndComments()
 
+	start := l.currentPosition()
+	token := l.scanToken()
+	token.Start = start
+	token.End = l.currentPosition()
+
+	if token.Type == ILLEGAL {
+		l.reportIllegal(token)
+	}
+	return token
+}
+
+// reportIllegal records why a token could not be lexed.
+func (l *Lexer) reportIllegal(tok Token) {
+	if tok.Literal != "" && strings.ContainsRune(`"'`+"`", rune(tok.Literal[0])) {
+		l.addError(tok.Start, E_UNTERMINATED_STRING, "unterminated string literal")
+		return
+	}
+	l.addError(tok.Start, E_ILLEGAL_CHARACTER, fmt.Sprintf("illegal character %q", tok.Literal))
+}
+
+// scanToken reads the token starting at the current char.
+func (l *Lexer) scanToken() Token {
+	var token Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -149,6 +394,13 @@ func (l *Lexer) NextToken() Token {
 			token = NewToken(ASSIGN, "=")
 		}
 
+	case '?':
+		if l.peekChar() == '?' {
+			l.readChar()
+			token = NewToken(COALESCE, "??")
+		} else {
+			token = NewToken(ILLEGAL, string(l.ch))
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -179,12 +431,36 @@ func (l *Lexer) NextToken() Token {
 			token = NewToken(LT, "<")
 		}
 
+	case '+':
+		token = NewToken(PLUS, "+")
+
+	case '-':
+		if l.peekChar() == '>' {
+			l.readChar()
+			token = NewToken(ARROW, "->")
+		} else {
+			token = NewToken(MINUS, "-")
+		}
+
+	case '*':
+		token = NewToken(ASTERISK, "*")
+
+	case '/':
+		// comments are already consumed by skipWhitespaceAndComments
+		token = NewToken(SLASH, "/")
+
+	case '%':
+		token = NewToken(PERCENT, "%")
+
 	case ';':
 		token = NewToken(SEMICOLON, ";")
 
 	case ',':
 		token = NewToken(COMMA, ",")
 
+	case ':':
+		token = NewToken(COLON, ":")
+
 	case '(':
 		token = NewToken(LPAREN, "(")
 
@@ -203,11 +479,9 @@ func (l *Lexer) NextToken() Token {
 	case '}':
 		token = NewToken(RBRACE, "}")
 
-	case '"', '\'':
-		quoteChar := l.ch
-		literal := l.readString()
-		// Ensure the string is properly quoted
-		if len(literal) < 2 || rune(literal[0]) != quoteChar || rune(literal[len(literal)-1]) != quoteChar {
+	case '"', '\'', '`':
+		literal, closed := l.readString()
+		if !closed {
 			token = NewToken(ILLEGAL, literal)
 		} else {
 			token = Token{Type: STRING, Literal: literal}
@@ -217,18 +491,29 @@ func (l *Lexer) NextToken() Token {
 	case '@':
 		literal := l.readVariable()
 		token = Token{Type: VARIABLE, Literal: literal}
+		return token // readVariable already advanced past the name
 
 	case 0:
 		token = Token{Type: EOF, Literal: ""}
 
 	default:
 		if isLetter(l.ch) {
+			start := l.position
 			literal := l.readIdentifier()
 			tokenType := LookupIdentifier(literal)
+			if tokenType == IDENTIFIER {
+				// The element named by a quantifier can be followed by a path, e.g. item.price
+				l.readPath()
+				literal = l.input[start:l.position]
+			}
 			token = NewToken(tokenType, literal)
 			return token
 		} else if isDigit(l.ch) {
 			literal := l.readNumber()
+			if isLetter(l.ch) && !strings.HasPrefix(strings.ToLower(literal), "0x") {
+				// A unit after the number makes it a duration, e.g. 30d or 1h30m
+				return NewToken(DURATION, literal+l.readDurationUnits())
+			}
 			token = NewToken(NUMBER, literal)
 			return token
 		} else {
@@ -0,0 +1,72 @@
+package parser
+
+import "testing"
+
+func TestLexerPositions(t *testing.T) {
+	input := "@age >= 18\n  AND @name == 'Jo'"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+		expectedStart   Position
+		expectedEnd     Position
+	}{
+		{VARIABLE, "@age", Position{0, 1, 1}, Position{4, 1, 5}},
+		{GTE, ">=", Position{5, 1, 6}, Position{7, 1, 8}},
+		{NUMBER, "18", Position{8, 1, 9}, Position{10, 1, 11}},
+		{AND, "AND", Position{13, 2, 3}, Position{16, 2, 6}},
+		{VARIABLE, "@name", Position{17, 2, 7}, Position{22, 2, 12}},
+		{EQ, "==", Position{23, 2, 13}, Position{25, 2, 15}},
+		{STRING, "'Jo'", Position{26, 2, 16}, Position{30, 2, 20}},
+		{EOF, "", Position{30, 2, 20}, Position{30, 2, 20}},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. got=%s(%q), want=%s(%q)",
+				i, tok.Type, tok.Literal, tt.expectedType, tt.expectedLiteral)
+		}
+		if tok.Start != tt.expectedStart || tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - wrong span for %q. got=%+v-%+v, want=%+v-%+v",
+				i, tok.Literal, tok.Start, tok.End, tt.expectedStart, tt.expectedEnd)
+		}
+	}
+}
+
+func TestLexerUnicode(t *testing.T) {
+	input := "@größe >= 10\nAND @名前 == 'Zoë 太郎' AND 項目1"
+
+	// Offsets count bytes, columns count characters
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+		expectedStart   Position
+		expectedEnd     Position
+	}{
+		{VARIABLE, "@größe", Position{0, 1, 1}, Position{8, 1, 7}},
+		{GTE, ">=", Position{9, 1, 8}, Position{11, 1, 10}},
+		{NUMBER, "10", Position{12, 1, 11}, Position{14, 1, 13}},
+		{AND, "AND", Position{15, 2, 1}, Position{18, 2, 4}},
+		{VARIABLE, "@名前", Position{19, 2, 5}, Position{26, 2, 8}},
+		{EQ, "==", Position{27, 2, 9}, Position{29, 2, 11}},
+		{STRING, "'Zoë 太郎'", Position{30, 2, 12}, Position{43, 2, 20}},
+		{AND, "AND", Position{44, 2, 21}, Position{47, 2, 24}},
+		{IDENTIFIER, "項目1", Position{48, 2, 25}, Position{55, 2, 28}},
+		{EOF, "", Position{55, 2, 28}, Position{55, 2, 28}},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. got=%s(%q), want=%s(%q)",
+				i, tok.Type, tok.Literal, tt.expectedType, tt.expectedLiteral)
+		}
+		if tok.Start != tt.expectedStart || tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - wrong span for %q. got=%+v-%+v, want=%+v-%+v",
+				i, tok.Literal, tok.Start, tok.End, tt.expectedStart, tt.expectedEnd)
+		}
+	}
+}
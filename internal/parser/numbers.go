@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number literals are decimal, like 42, -3, 0.5 and 1e6, or hexadecimal, like
+// 0x1F. Underscores may separate digits, as in 1_000_000. A literal without a
+// fraction or exponent is an integer and keeps its exact value as an int64, so
+// IDs above 2^53 survive compilation. Decimal integers beyond the range of an
+// int64 keep their digits as a json.Number; everything else is a float64.
+
+// parseNumber converts the source of a number literal, with an optional sign,
+// into an int64, a json.Number or a float64
+func parseNumber(text string) (interface{}, error) {
+	digits := strings.TrimLeft(text, "+-")
+	hex := strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X")
+	if !validUnderscores(digits, hex) {
+		return nil, fmt.Errorf("invalid number %s: '_' must separate digits", text)
+	}
+
+	clean := strings.ReplaceAll(text, "_", "")
+	if hex || !strings.ContainsAny(clean, ".eE") {
+		n, err := strconv.ParseInt(clean, 0, 64)
+		if err == nil {
+			return n, nil
+		}
+		if !hex && errors.Is(err, strconv.ErrRange) {
+			// A float64 would round the digits, so keep them as written
+			return json.Number(strings.TrimPrefix(clean, "+")), nil
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("number %s is out of range", text)
+		}
+		return nil, fmt.Errorf("invalid number %s", text)
+	}
+
+	f, err := strconv.ParseFloat(clean, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("number %s is out of range", text)
+		}
+		return nil, fmt.Errorf("invalid number %s", text)
+	}
+	return f, nil
+}
+
+// validUnderscores reports whether every underscore in digits sits between two digits
+func validUnderscores(digits string, hex bool) bool {
+	isNumberDigit := func(ch byte) bool {
+		return isDigit(rune(ch)) || (hex && isHexDigit(rune(ch)))
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(digits)-1 || !isNumberDigit(digits[i-1]) || !isNumberDigit(digits[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHexDigit reports whether ch is a hexadecimal digit
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+// negateNumber folds a minus sign into the number literal that follows it.
+// The value is parsed again so -9223372036854775808 is still an int64.
+func negateNumber(minus Token, n *Literal) *Literal {
+	token := NewToken(NUMBER, "-"+n.Token.Literal)
+	token.Start, token.End = minus.Start, n.Token.End
+
+	// Errors were reported for the unsigned literal
+	value, _ := parseNumber(token.Literal)
+	return &Literal{Token: token, Value: value, Span: Span{Start: minus.Start, End: n.Span.End}}
+}
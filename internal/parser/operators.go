@@ -6,27 +6,44 @@ import "fmt"
 type OperatorPrecedence int
 
 const (
-	LOWEST     OperatorPrecedence = iota
-	LOGICAL                       // AND, OR
-	EQUALS                        // ==, !=, ===, !==
-	COMPARISON                    // >, <, >=, <=, IN
-	PREFIX                        // NOT, !
-	CALL                          // function calls
+	LOWEST      OperatorPrecedence = iota
+	LOGICAL_OR                     // OR
+	LOGICAL_AND                    // AND
+	COMPARISON                     // =, ==, ===, !=, !==, >, <, >=, <=, IN, BETWEEN, IS, CONTAINS
+	FALLBACK                       // ??
+	SUM                            // +, -
+	PRODUCT                        // *, /, %
+	PREFIX                         // NOT, !, unary -
+	CALL                           // function calls
 )
 
-// Map tokens to precedence levels
+// Map tokens to precedence levels. The levels mirror the recursive descent in
+// parser_expressions.go and parser_core.go: OR binds loosest, then AND, and all
+// comparison operators share one level.
 var precedences = map[TokenType]OperatorPrecedence{
-	EQ:         EQUALS,
-	STRICT_EQ:  EQUALS,
-	NOT_EQ:     EQUALS,
-	STRICT_NOT: EQUALS,
-	LT:         COMPARISON,
-	GT:         COMPARISON,
-	LTE:        COMPARISON,
-	GTE:        COMPARISON,
-	IN:         COMPARISON,
-	AND:        LOGICAL,
-	OR:         LOGICAL,
+	ASSIGN:      COMPARISON,
+	EQ:          COMPARISON,
+	STRICT_EQ:   COMPARISON,
+	NOT_EQ:      COMPARISON,
+	STRICT_NOT:  COMPARISON,
+	LT:          COMPARISON,
+	GT:          COMPARISON,
+	LTE:         COMPARISON,
+	GTE:         COMPARISON,
+	IN:          COMPARISON,
+	BETWEEN:     COMPARISON,
+	IS:          COMPARISON,
+	CONTAINS:    COMPARISON,
+	STARTS_WITH: COMPARISON,
+	ENDS_WITH:   COMPARISON,
+	COALESCE:    FALLBACK,
+	PLUS:        SUM,
+	MINUS:       SUM,
+	ASTERISK:    PRODUCT,
+	SLASH:       PRODUCT,
+	PERCENT:     PRODUCT,
+	AND:         LOGICAL_AND,
+	OR:          LOGICAL_OR,
 }
 
 // Helper functions for token checking
@@ -54,13 +71,29 @@ func (p *Parser) isComparisonOperator(tokenType TokenType) bool {
 	}
 }
 
+// isAdditiveOperator checks if the token type is an additive operator
+func (p *Parser) isAdditiveOperator(tokenType TokenType) bool {
+	return tokenType == PLUS || tokenType == MINUS
+}
+
+// isMultiplicativeOperator checks if the token type is a multiplicative operator
+func (p *Parser) isMultiplicativeOperator(tokenType TokenType) bool {
+	switch tokenType {
+	case ASTERISK, SLASH, PERCENT:
+		return true
+	default:
+		return false
+	}
+}
+
 // Helper methods for token checking and error handling
 func (p *Parser) expectPeek(t TokenType) bool {
 	if p.peekTokenIs(t) {
 		p.nextToken()
 		return true
 	}
-	p.addError(fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type))
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	p.addDiagnostic(newExpectedDiagnostic(p.peekToken, msg, t))
 	return false
 }
 
@@ -4,13 +4,25 @@ import "fmt"
 
 type Parser struct {
 	lexer        *Lexer
+	registry     *Registry
 	currentToken Token
 	peekToken    Token
-	errors       []string
+	diagnostics  []Diagnostic
+	scopes       []scope // The quantifier and lambda bodies being parsed, innermost last
 }
 
+// NewParser creates a parser that accepts the builtin functions
 func NewParser(l *Lexer) *Parser {
-	p := &Parser{lexer: l}
+	return NewParserWithRegistry(l, nil)
+}
+
+// NewParserWithRegistry creates a parser that accepts the functions and
+// operators held by registry; a nil registry means only the builtins
+func NewParserWithRegistry(l *Lexer, registry *Registry) *Parser {
+	if registry == nil {
+		registry = defaultRegistry
+	}
+	p := &Parser{lexer: l, registry: registry}
 	// Read two tokens to initialize currentToken and peekToken
 	p.nextToken()
 	p.nextToken()
@@ -20,18 +32,76 @@ func NewParser(l *Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+	// Lexical errors are reported in source order alongside parse errors
+	for _, d := range p.lexer.takeDiagnostics() {
+		p.addDiagnostic(d)
+	}
 }
 
+// Errors returns the parser diagnostics formatted as "line:column: message"
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, len(p.diagnostics))
+	for i, d := range p.diagnostics {
+		errors[i] = d.String()
+	}
+	return errors
+}
+
+// Comments returns the comments the lexer has skipped so far. After
+// ParseProgram this is every comment in the input.
+func (p *Parser) Comments() []Comment {
+	return p.lexer.Comments()
+}
+
+// Diagnostics returns every diagnostic reported while parsing
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// HasErrors reports whether any error-severity diagnostic was recorded
+func (p *Parser) HasErrors() bool {
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
 }
 
-func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, msg)
+// addDiagnostic records a diagnostic. An error starting at the same position
+// as the previous error is a consequence of it and is dropped, which keeps
+// recovery from producing cascades of follow-up errors.
+func (p *Parser) addDiagnostic(d Diagnostic) {
+	if n := len(p.diagnostics); n > 0 && d.Severity == SeverityError {
+		last := p.diagnostics[n-1]
+		if last.Severity == SeverityError && last.Span.Start.Offset == d.Span.Start.Offset {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// addError records an error at the position of the current token
+func (p *Parser) addError(code DiagnosticCode, msg string) {
+	p.addErrorAt(p.currentToken, code, msg)
 }
 
 // addErrorf adds a formatted error message to the parser's error list
-func (p *Parser) addErrorf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	p.errors = append(p.errors, msg)
+func (p *Parser) addErrorf(code DiagnosticCode, format string, args ...interface{}) {
+	p.addErrorAt(p.currentToken, code, fmt.Sprintf(format, args...))
+}
+
+// addErrorAt records an error covering the given token
+func (p *Parser) addErrorAt(tok Token, code DiagnosticCode, msg string) {
+	p.addDiagnostic(newErrorDiagnostic(tok, code, msg))
+}
+
+// addErrorSpan records an error covering an arbitrary span
+func (p *Parser) addErrorSpan(span Span, code DiagnosticCode, msg string) {
+	p.addDiagnostic(Diagnostic{Severity: SeverityError, Code: code, Message: msg, Span: span})
+}
+
+// addExpectedError records that one of the expected token types was missing at the current token
+func (p *Parser) addExpectedError(msg string, expected ...TokenType) {
+	p.addDiagnostic(newExpectedDiagnostic(p.currentToken, msg, expected...))
 }
@@ -0,0 +1,125 @@
+package parser
+
+import "fmt"
+
+// This file contains the parsing of FILTER, MAP and REDUCE and their lambdas
+
+// parseCollectionExpression handles FILTER(collection, x -> body),
+// MAP(collection, x -> body) and REDUCE(collection, (acc, x) -> body, initial)
+func (p *Parser) parseCollectionExpression() Expression {
+	ce := &CollectionExpression{Token: p.currentToken}
+	keyword := string(ce.Token.Type)
+	p.nextToken() // consume FILTER, MAP or REDUCE
+	open := p.currentToken
+	p.nextToken() // consume '('
+
+	ce.Collection = p.ParseExpression()
+	if !p.currentTokenIs(COMMA) {
+		p.addExpectedError("expected ',' after the "+keyword+" collection", COMMA)
+		return p.recoverUntilClosing(open, RPAREN)
+	}
+	p.nextToken() // consume ','
+
+	params, ok := p.parseLambdaParams(ce.Token)
+	if !ok {
+		return p.recoverUntilClosing(open, RPAREN)
+	}
+	ce.Params = params
+
+	// The data REDUCE evaluates its lambda against holds the accumulator and
+	// the element under fixed keys; FILTER and MAP use the element itself
+	bindings := make([]binding, len(params))
+	for i, name := range params {
+		bindings[i] = binding{name: name}
+	}
+	if ce.Token.Type == REDUCE && len(params) == 2 {
+		bindings[0].key, bindings[1].key = "accumulator", "current"
+	}
+	p.pushScope(ce.Token.Type, bindings...)
+	ce.Body = p.ParseExpression()
+	p.popScope()
+
+	if ce.Token.Type == REDUCE {
+		if !p.currentTokenIs(COMMA) {
+			p.addExpectedError("expected ',' and the initial value after the REDUCE lambda", COMMA)
+			return p.recoverUntilClosing(open, RPAREN)
+		}
+		p.nextToken() // consume ','
+		ce.Initial = p.ParseExpression()
+	}
+
+	if !p.currentTokenIs(RPAREN) {
+		p.addExpectedError("expected right parenthesis", RPAREN)
+		return p.recoverUntilClosing(open, RPAREN)
+	}
+	ce.Span = Span{Start: ce.Token.Start, End: p.currentToken.End}
+	p.nextToken() // consume ')'
+	return ce
+}
+
+// parseLambdaParams parses the parameters of a lambda up to and including
+// the '->': a single name, o ->, or a parenthesised list, (sum, o) ->. Only
+// a syntax error stops the lambda from being parsed; parameters that do not
+// fit the operation are reported and returned as written.
+func (p *Parser) parseLambdaParams(keyword Token) ([]string, bool) {
+	start := p.currentToken
+	want, example := 1, "o -> o.total"
+	if keyword.Type == REDUCE {
+		want, example = 2, "(sum, o) -> sum + o.total"
+	}
+
+	var params []string
+	switch {
+	case p.currentTokenIs(IDENTIFIER):
+		params = append(params, p.currentToken.Literal)
+		p.nextToken()
+	case p.currentTokenIs(LPAREN):
+		p.nextToken() // consume '('
+		for p.currentTokenIs(IDENTIFIER) {
+			params = append(params, p.currentToken.Literal)
+			p.nextToken()
+			if !p.currentTokenIs(COMMA) {
+				break
+			}
+			p.nextToken() // consume ','
+		}
+		if !p.currentTokenIs(RPAREN) {
+			p.addExpectedError("expected ')' after the lambda parameters", RPAREN)
+			return nil, false
+		}
+		p.nextToken() // consume ')'
+	default:
+		p.addExpectedError(fmt.Sprintf("expected a lambda such as %s", example), IDENTIFIER, LPAREN)
+		return nil, false
+	}
+
+	if !p.currentTokenIs(ARROW) {
+		p.addExpectedError("expected '->' after the lambda parameters", ARROW)
+		return nil, false
+	}
+	span := Span{Start: start.Start, End: p.currentToken.End}
+	p.nextToken() // consume '->'
+
+	if len(params) != want {
+		p.addErrorSpan(span, E_ARGUMENT_COUNT, fmt.Sprintf("the %s lambda takes %s, got %d", keyword.Type, pluralParameters(want), len(params)))
+		return params, true
+	}
+	for i, name := range params {
+		if !isIdentifierName(name) {
+			p.addErrorSpan(span, E_INVALID_EXPRESSION, fmt.Sprintf("invalid lambda parameter %s", name))
+			break
+		}
+		if i > 0 && params[0] == name {
+			p.addErrorSpan(span, E_INVALID_EXPRESSION, fmt.Sprintf("duplicate lambda parameter %s", name))
+			break
+		}
+	}
+	return params, true
+}
+
+func pluralParameters(n int) string {
+	if n == 1 {
+		return "1 parameter"
+	}
+	return fmt.Sprintf("%d parameters", n)
+}
@@ -0,0 +1,73 @@
+package parser
+
+// This file contains the parsing of conditional expressions
+
+// parseIfExpression handles IF cond THEN a [ELSE b]. The ELSE branch extends
+// as far right as possible, so IF binds looser than any operator.
+func (p *Parser) parseIfExpression() Expression {
+	ce := &ConditionalExpression{Token: p.currentToken}
+	p.nextToken() // consume IF
+
+	branch, ok := p.parseConditionalBranch("IF")
+	if !ok {
+		return p.recoverFrom(ce.Token)
+	}
+	ce.Branches = []ConditionalBranch{branch}
+	end := spanOf(branch.Result, ce.Token).End
+
+	if p.currentTokenIs(ELSE) {
+		p.nextToken() // consume ELSE
+		ce.Else = p.ParseExpression()
+		end = spanOf(ce.Else, ce.Token).End
+	}
+
+	ce.Span = Span{Start: ce.Token.Start, End: end}
+	return ce
+}
+
+// parseCaseExpression handles CASE WHEN cond THEN a ... [ELSE b] END
+func (p *Parser) parseCaseExpression() Expression {
+	ce := &ConditionalExpression{Token: p.currentToken}
+	p.nextToken() // consume CASE
+
+	if !p.currentTokenIs(WHEN) {
+		p.addExpectedError("expected WHEN after CASE", WHEN)
+		return p.recoverFrom(ce.Token)
+	}
+	for p.currentTokenIs(WHEN) {
+		p.nextToken() // consume WHEN
+		branch, ok := p.parseConditionalBranch("WHEN")
+		if !ok {
+			return p.recoverUntilClosing(ce.Token, END)
+		}
+		ce.Branches = append(ce.Branches, branch)
+	}
+
+	if p.currentTokenIs(ELSE) {
+		p.nextToken() // consume ELSE
+		ce.Else = p.ParseExpression()
+	}
+
+	if !p.currentTokenIs(END) {
+		p.addExpectedError("expected END to close CASE", END)
+		return p.recoverUntilClosing(ce.Token, END)
+	}
+	ce.Span = Span{Start: ce.Token.Start, End: p.currentToken.End}
+	p.nextToken() // consume END
+	return ce
+}
+
+// parseConditionalBranch parses "cond THEN result" after IF or WHEN
+func (p *Parser) parseConditionalBranch(keyword string) (ConditionalBranch, bool) {
+	var branch ConditionalBranch
+	branch.Condition = p.ParseExpression()
+
+	if !p.currentTokenIs(THEN) {
+		p.addExpectedError("expected THEN after "+keyword+" condition", THEN)
+		return branch, false
+	}
+	p.nextToken() // consume THEN
+
+	branch.Result = p.ParseExpression()
+	return branch, true
+}
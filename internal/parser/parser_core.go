@@ -1,41 +1,76 @@
 package parser
 
+import (
+	"fmt"
+	"strings"
+)
+
 // This file contains the core parser functionality
 
-// parseComparisonExpression handles ==, ===, !=, !==, >, <, >=, <=, IN operations
+// parseComparisonExpression handles ==, ===, !=, !==, >, <, >=, <=, IN, BETWEEN,
+// CONTAINS, STARTS_WITH and ENDS_WITH operations
 func (p *Parser) parseComparisonExpression() Expression {
-	left := p.parsePrimaryExpression()
+	left := p.parseDefaultExpression()
 
 	// Check for NOT IN pattern
 	if p.isNotInPattern() {
 		return p.parseNotInExpression(left)
 	}
 
+	// Check for NOT BETWEEN pattern
+	if p.isNotBetweenPattern() {
+		return p.parseNotBetweenExpression(left)
+	}
+
 	// Handle IN operator specially
 	if p.currentTokenIs(IN) {
 		return p.parseInExpression(left)
 	}
 
+	// BETWEEN takes two bounds separated by AND
+	if p.currentTokenIs(BETWEEN) {
+		return p.parseBetweenExpression(left)
+	}
+
+	// Substring tests, @email ENDS_WITH '@example.com'
+	if p.currentTokenIs(CONTAINS) || p.currentTokenIs(STARTS_WITH) || p.currentTokenIs(ENDS_WITH) {
+		return p.parseStringExpression(left)
+	}
+
+	// Presence check, @email IS [NOT] MISSING
+	if p.currentTokenIs(IS) {
+		return p.parseIsMissingExpression(left)
+	}
+
+	// Operators registered by the application, e.g. @location GEO_WITHIN @region
+	if fn, ok := p.registeredOperator(); ok {
+		return p.parseOperatorExpression(left, fn)
+	}
+
 	// Handle other comparison operators
 	for p.isComparisonOperator(p.currentToken.Type) {
 		token := p.currentToken
 		p.nextToken()
-		right := p.parsePrimaryExpression()
-		left = &BinaryExpression{
-			Token:    token,
-			Left:     left,
-			Operator: token.Literal,
-			Right:    right,
-		}
+		operands := []Expression{left, p.parseDefaultExpression()}
+		p.coerceDates(token.Type, operands)
+		left = newBinaryExpression(token, operands[0], operands[1])
 	}
 	return left
 }
 
 // isNotInPattern checks if the current tokens form a NOT IN pattern
 func (p *Parser) isNotInPattern() bool {
-	return p.currentToken.Type == BANG &&
-		p.currentToken.Literal == "NOT" &&
-		p.peekToken.Type == IN
+	return p.isNotKeyword() && p.peekToken.Type == IN
+}
+
+// isNotBetweenPattern checks if the current tokens form a NOT BETWEEN pattern
+func (p *Parser) isNotBetweenPattern() bool {
+	return p.isNotKeyword() && p.peekToken.Type == BETWEEN
+}
+
+// isNotKeyword checks if the current token is the NOT keyword rather than '!'
+func (p *Parser) isNotKeyword() bool {
+	return p.currentToken.Type == BANG && strings.EqualFold(p.currentToken.Literal, "NOT")
 }
 
 // parseNotInExpression handles the NOT IN special case
@@ -45,59 +80,222 @@ func (p *Parser) parseNotInExpression(left Expression) Expression {
 	inToken := p.currentToken
 	p.nextToken() // consume IN
 
-	// Ensure we have an array after IN
-	if !p.currentTokenIs(LBRACKET) {
-		p.addError("expected array after NOT IN operator")
-		return nil
+	right := p.parseDefaultExpression()
+	p.checkInOperand(inToken, right)
+
+	// Create IN expression
+	inExpr := newBinaryExpression(inToken, left, right)
+
+	// Wrap with NOT; the span starts at the left operand, which precedes NOT
+	notExpr := newUnaryExpression(notToken, inExpr)
+	notExpr.Span.Start = inExpr.Span.Start
+	return notExpr
+}
+
+// parseInExpression handles the IN operator. Like JSONLogic's in, it tests
+// membership of an array, @role IN ['admin', 'owner'], or of a substring,
+// 'admin' IN @title.
+func (p *Parser) parseInExpression(left Expression) Expression {
+	token := p.currentToken
+	p.nextToken() // consume IN
+
+	right := p.parseDefaultExpression()
+	p.checkInOperand(token, right)
+
+	return newBinaryExpression(token, left, right)
+}
+
+// checkInOperand reports a right-hand side of IN that is neither an array nor a string
+func (p *Parser) checkInOperand(token Token, right Expression) {
+	if t := staticType(right); t == TYPE_NUMBER || t == TYPE_BOOLEAN {
+		p.addErrorSpan(spanOf(right, token), E_ARGUMENT_TYPE, fmt.Sprintf("IN expects an array or a string, got %s", t))
 	}
+}
 
-	// Parse the array literal
-	right := p.parseArrayLiteral()
-	if right == nil {
-		p.addError("failed to parse array after NOT IN operator")
-		return nil
+// parseStringExpression handles value CONTAINS substring, value STARTS_WITH
+// prefix and value ENDS_WITH suffix. A prefix or suffix must be a string
+// literal, because its length is written into the JSONLogic emitted for it.
+func (p *Parser) parseStringExpression(left Expression) Expression {
+	token := p.currentToken
+	p.nextToken() // consume the operator
+
+	right := p.parseDefaultExpression()
+	if _, ok := stringLiteral(right); !ok && token.Type != CONTAINS {
+		p.addErrorSpan(spanOf(right, token), E_ARGUMENT_TYPE, fmt.Sprintf("%s expects a string literal", token.Type))
 	}
+	return newBinaryExpression(token, left, right)
+}
 
-	// Create IN expression
-	inExpr := &BinaryExpression{
-		Token:    inToken,
-		Left:     left,
-		Operator: inToken.Literal,
-		Right:    right,
+// parseNotBetweenExpression handles the NOT BETWEEN special case
+func (p *Parser) parseNotBetweenExpression(left Expression) Expression {
+	notToken := p.currentToken
+	p.nextToken() // consume NOT
+
+	between := p.parseBetweenExpression(left)
+
+	// Wrap with NOT; the span starts at the left operand, which precedes NOT
+	notExpr := newUnaryExpression(notToken, between)
+	notExpr.Span.Start = between.Location().Start
+	return notExpr
+}
+
+// parseBetweenExpression handles value BETWEEN lower AND upper [EXCLUSIVE].
+// The bounds are arithmetic expressions, so the AND separating them is never
+// mistaken for a logical AND.
+func (p *Parser) parseBetweenExpression(value Expression) Expression {
+	between := &BetweenExpression{Token: p.currentToken, Value: value}
+	p.nextToken() // consume BETWEEN
+
+	between.Lower = p.parseDefaultExpression()
+
+	if p.currentTokenIs(AND) {
+		p.nextToken() // consume AND
+		between.Upper = p.parseDefaultExpression()
+	} else {
+		p.addExpectedError("expected AND between the bounds of BETWEEN", AND)
+		between.Upper = p.recoverFrom(p.currentToken)
+	}
+
+	operands := []Expression{between.Value, between.Lower, between.Upper}
+	p.coerceDates(BETWEEN, operands)
+	between.Value, between.Lower, between.Upper = operands[0], operands[1], operands[2]
+
+	end := spanOf(between.Upper, between.Token).End
+	if p.currentTokenIs(EXCLUSIVE) {
+		between.Exclusive = true
+		end = p.currentToken.End
+		p.nextToken() // consume EXCLUSIVE
+	}
+
+	between.Span = Span{Start: spanOf(value, between.Token).Start, End: end}
+	return between
+}
+
+// parseIsMissingExpression handles variable IS [NOT] MISSING
+func (p *Parser) parseIsMissingExpression(left Expression) Expression {
+	me := &MissingExpression{Token: p.currentToken}
+	p.nextToken() // consume IS
+
+	if p.isNotKeyword() {
+		me.Negated = true
+		p.nextToken() // consume NOT
+	}
+	if !p.currentTokenIs(MISSING) {
+		p.addExpectedError("expected MISSING after IS", MISSING)
+		return p.recoverFrom(p.currentToken)
 	}
 
-	// Wrap with NOT
-	return &UnaryExpression{
-		Token:    notToken,
-		Operator: notToken.Literal,
-		Right:    inExpr,
+	variable, ok := left.(*Variable)
+	if !ok {
+		if left != nil {
+			p.addErrorSpan(left.Location(), E_INVALID_EXPRESSION, "only a variable can be checked with IS MISSING")
+		}
+		variable = &Variable{Token: me.Token, Span: me.Token.Span()}
 	}
+	me.Variables = []*Variable{variable}
+	me.Span = Span{Start: spanOf(left, me.Token).Start, End: p.currentToken.End}
+	p.nextToken() // consume MISSING
+	return me
 }
 
-// parseInExpression handles the IN operator
-func (p *Parser) parseInExpression(left Expression) Expression {
-	token := p.currentToken
-	p.nextToken() // consume IN
+// parseExistsExpression handles EXISTS variable
+func (p *Parser) parseExistsExpression() Expression {
+	me := &MissingExpression{Token: p.currentToken, Negated: true}
+	p.nextToken() // consume EXISTS
+
+	if !p.currentTokenIs(VARIABLE) {
+		p.addExpectedError("expected a variable after EXISTS", VARIABLE)
+		return p.recoverFrom(p.currentToken)
+	}
+	variable := newVariable(p.currentToken)
+	me.Variables = []*Variable{variable}
+	me.Span = Span{Start: me.Token.Start, End: variable.Span.End}
+	p.nextToken()
+	return me
+}
+
+// parseMissingSomeExpression handles MISSING_SOME(need, [@a, @b, ...])
+func (p *Parser) parseMissingSomeExpression() Expression {
+	me := &MissingExpression{Token: p.currentToken}
+
+	args, ok := p.parseCallArguments()
+	if !ok {
+		return p.recoverUntilClosing(me.Token, RPAREN)
+	}
+	me.Span = Span{Start: me.Token.Start, End: p.currentToken.End}
+	p.nextToken()
 
-	// Ensure we have an array after IN
-	if !p.currentTokenIs(LBRACKET) {
-		p.addError("expected array after IN operator")
-		return nil
+	if len(args) != 2 {
+		p.addErrorSpan(me.Span, E_ARGUMENT_COUNT, fmt.Sprintf("MISSING_SOME expects 2 arguments, got %d", len(args)))
+		return me
 	}
+	me.Need = args[0]
 
-	// Parse the array literal
-	right := p.parseArrayLiteral()
-	if right == nil {
-		p.addError("failed to parse array after IN operator")
-		return nil
+	array, ok := args[1].(*ArrayLiteral)
+	if !ok {
+		p.addErrorSpan(spanOf(args[1], me.Token), E_INVALID_EXPRESSION, "MISSING_SOME expects an array of variables")
+		return me
+	}
+	for _, elem := range array.Elements {
+		variable, ok := elem.(*Variable)
+		if !ok {
+			p.addErrorSpan(spanOf(elem, me.Token), E_INVALID_EXPRESSION, "MISSING_SOME expects an array of variables")
+			continue
+		}
+		me.Variables = append(me.Variables, variable)
 	}
+	return me
+}
 
-	return &BinaryExpression{
-		Token:    token,
-		Left:     left,
-		Operator: token.Literal,
-		Right:    right,
+// registeredOperator returns the registered infix operator named by the current token
+func (p *Parser) registeredOperator() (*Function, bool) {
+	if !p.currentTokenIs(IDENTIFIER) {
+		return nil, false
 	}
+	return p.registry.Operator(p.currentToken.Literal)
+}
+
+// parseOperatorExpression handles left NAME right for a registered operator.
+// Both operands are arithmetic expressions, like the operands of IN.
+func (p *Parser) parseOperatorExpression(left Expression, fn *Function) Expression {
+	fc := &FunctionCall{
+		Token:      p.currentToken,
+		Function:   fn.Name,
+		Infix:      true,
+		Definition: fn,
+	}
+	p.nextToken() // consume the operator
+
+	right := p.parseDefaultExpression()
+	if fn.pattern {
+		p.keepPatternEscapes(right)
+	}
+	fc.Arguments = []Expression{left, right}
+	fc.Span = Span{Start: spanOf(left, fc.Token).Start, End: spanOf(right, fc.Token).End}
+
+	p.checkArguments(fc, fn)
+	return fc
+}
+
+// keepPatternEscapes withdraws the lexer's errors for escapes like \d in a
+// quoted pattern, which unquote keeps as written. Malformed \u escapes are
+// still errors.
+func (p *Parser) keepPatternEscapes(exp Expression) {
+	lit, ok := exp.(*Literal)
+	if !ok || lit.Token.Type != STRING || lit.Token.Literal[0] == '`' {
+		return
+	}
+
+	kept := p.diagnostics[:0]
+	for _, d := range p.diagnostics {
+		i := d.Span.Start.Offset - lit.Token.Start.Offset
+		inside := i > 0 && i+1 < len(lit.Token.Literal)
+		if d.Code == E_INVALID_ESCAPE && inside && lit.Token.Literal[i+1] != 'u' {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	p.diagnostics = kept
 }
 
 // parsePrimaryExpression handles basic expressions like variables, literals, and parenthesized expressions
@@ -106,7 +304,7 @@ func (p *Parser) parsePrimaryExpression() Expression {
 	case LPAREN:
 		return p.parseParenthesizedExpression()
 
-	case BANG:
+	case BANG, MINUS:
 		return p.parseUnaryExpression()
 
 	case VARIABLE:
@@ -115,9 +313,18 @@ func (p *Parser) parsePrimaryExpression() Expression {
 	case NUMBER:
 		return p.parseNumberLiteral()
 
+	case DURATION:
+		return p.parseDurationLiteral()
+
 	case STRING:
 		return p.parseStringLiteral()
 
+	case TRUE, FALSE:
+		return p.parseBooleanLiteral()
+
+	case NULL:
+		return p.parseNullLiteral()
+
 	case LBRACKET:
 		return p.parseArrayLiteral()
 
@@ -127,68 +334,148 @@ func (p *Parser) parsePrimaryExpression() Expression {
 		}
 		return p.parseIdentifier()
 
+	case LOG:
+		if p.peekToken.Type == LPAREN {
+			return p.parseFunctionCall()
+		}
+		p.addExpectedError("expected '(' after LOG", LPAREN)
+		return p.recoverFrom(p.currentToken)
+
+	case EXISTS:
+		return p.parseExistsExpression()
+
+	case IF:
+		return p.parseIfExpression()
+
+	case ANY, ALL, NONE:
+		return p.parseQuantifierExpression()
+
+	case FILTER, MAP, REDUCE:
+		if p.peekToken.Type == LPAREN {
+			return p.parseCollectionExpression()
+		}
+		p.addExpectedError("expected '(' after "+string(p.currentToken.Type), LPAREN)
+		return p.recoverFrom(p.currentToken)
+
+	case CASE:
+		return p.parseCaseExpression()
+
+	case MISSING_SOME:
+		if p.peekToken.Type == LPAREN {
+			return p.parseMissingSomeExpression()
+		}
+		p.addExpectedError("expected '(' after MISSING_SOME", LPAREN)
+		return p.recoverFrom(p.currentToken)
+
+	case DEFAULT:
+		if p.peekToken.Type == LPAREN {
+			return p.parseDefaultCall()
+		}
+		p.addExpectedError("expected '(' after DEFAULT", LPAREN)
+		return p.recoverFrom(p.currentToken)
+
+	case ILLEGAL:
+		// already reported by the lexer
+		return p.recoverFrom(p.currentToken)
+
 	default:
-		p.addErrorf("unexpected token: %s", p.currentToken.Type)
-		return nil
+		tok := p.currentToken
+		p.addErrorf(E_UNEXPECTED_TOKEN, "unexpected token: %s", tok.Type)
+		return p.recoverFrom(tok)
 	}
 }
 
 // parseParenthesizedExpression handles expressions in parentheses
 func (p *Parser) parseParenthesizedExpression() Expression {
+	open := p.currentToken
 	p.nextToken() // consume '('
 	exp := p.ParseExpression()
 
 	if !p.currentTokenIs(RPAREN) {
-		p.addError("expected right parenthesis")
-		return nil
+		p.addExpectedError("expected right parenthesis", RPAREN)
+		return p.recoverUntilClosing(open, RPAREN)
 	}
 	p.nextToken() // consume ')'
 	return exp
 }
 
-// parseUnaryExpression handles unary operations like NOT
+// parseUnaryExpression handles unary operations like NOT and negation
 func (p *Parser) parseUnaryExpression() Expression {
 	token := p.currentToken
 	p.nextToken() // consume operator
 	right := p.parsePrimaryExpression()
 
-	return &UnaryExpression{
-		Token:    token,
-		Operator: token.Literal,
-		Right:    right,
+	// -3 is a negative literal rather than a negation
+	if lit, ok := right.(*Literal); ok && token.Type == MINUS && lit.Token.Type == NUMBER && lit.Token.Literal[0] != '-' {
+		return negateNumber(token, lit)
 	}
+	return newUnaryExpression(token, right)
 }
 
-// parseVariable handles variable references like @age
+// parseVariable handles variable references like @age and @user.address.city
 func (p *Parser) parseVariable() Expression {
-	variable := &Variable{Token: p.currentToken, Name: p.currentToken.Literal}
+	variable := newVariable(p.currentToken)
+	p.checkScope(p.currentToken, p.currentToken.Literal)
 	p.nextToken()
 	return variable
 }
 
 // parseNumberLiteral handles numeric literals
 func (p *Parser) parseNumberLiteral() Expression {
-	lit := &Literal{Token: p.currentToken, Value: p.currentToken.Literal}
+	lit := &Literal{Token: p.currentToken, Span: p.currentToken.Span()}
+	value, err := parseNumber(p.currentToken.Literal)
+	if err != nil {
+		p.addErrorAt(p.currentToken, E_INVALID_NUMBER, err.Error())
+	}
+	lit.Value = value
 	p.nextToken()
 	return lit
 }
 
-// parseStringLiteral handles string literals
+// parseStringLiteral handles quoted and raw string literals. The token keeps
+// the source; the value has the quotes removed and escapes decoded.
 func (p *Parser) parseStringLiteral() Expression {
-	// Remove quotes for the value but keep them in the token
-	literal := p.currentToken.Literal
-	value := literal
-	if len(literal) >= 2 && (literal[0] == '"' || literal[0] == '\'') {
-		value = literal[1 : len(literal)-1]
-	}
-	lit := &Literal{Token: p.currentToken, Value: value}
+	lit := &Literal{Token: p.currentToken, Value: unquote(p.currentToken.Literal), Span: p.currentToken.Span()}
 	p.nextToken()
 	return lit
 }
 
-// parseIdentifier handles identifiers
+// parseBooleanLiteral handles the true and false keywords
+func (p *Parser) parseBooleanLiteral() Expression {
+	lit := &Literal{Token: p.currentToken, Value: p.currentTokenIs(TRUE), Span: p.currentToken.Span()}
+	p.nextToken()
+	return lit
+}
+
+// parseNullLiteral handles the null keyword
+func (p *Parser) parseNullLiteral() Expression {
+	lit := &Literal{Token: p.currentToken, Value: nil, Span: p.currentToken.Span()}
+	p.nextToken()
+	return lit
+}
+
+// parseIdentifier handles identifiers: references to a name brought into
+// scope by a quantifier or lambda like item.price, and bare words, which are
+// read as strings
 func (p *Parser) parseIdentifier() Expression {
-	lit := &Literal{Token: p.currentToken, Value: p.currentToken.Literal}
+	tok := p.currentToken
+	path := variablePath(tok.Literal)
+	if b, ok := p.lookupBinding(path[0]); ok {
+		p.nextToken()
+		return newElementVariable(tok, b.key)
+	}
+	switch {
+	case p.boundOutside(path[0]):
+		p.checkScope(tok, path[0])
+		p.nextToken()
+		return newElementVariable(tok, "")
+	case len(path) > 1:
+		p.addErrorAt(tok, E_INVALID_VARIABLE, fmt.Sprintf("%s is not in scope", path[0]))
+		p.nextToken()
+		return newElementVariable(tok, "")
+	}
+
+	lit := &Literal{Token: p.currentToken, Value: p.currentToken.Literal, Span: p.currentToken.Span()}
 	p.nextToken()
 	return lit
 }
@@ -1,7 +1,39 @@
 package parser
 
+import "fmt"
+
 // This file contains the expression parsing logic for the parser
 
+// ParseProgram parses the entire input as a single expression. Unlike
+// ParseExpression it requires the input to end after the expression, so
+// trailing tokens are reported instead of silently ignored. Callers should
+// check HasErrors before using the result.
+func (p *Parser) ParseProgram() Expression {
+	exp := p.ParseExpression()
+
+	// ILLEGAL tokens have already been reported by the lexer
+	for p.currentTokenIs(ILLEGAL) {
+		p.nextToken()
+	}
+
+	if !p.currentTokenIs(EOF) {
+		start := p.currentToken
+		end := start
+		for !p.currentTokenIs(EOF) {
+			end = p.currentToken
+			p.nextToken()
+		}
+		p.addDiagnostic(Diagnostic{
+			Severity: SeverityError,
+			Code:     E_TRAILING_INPUT,
+			Message:  fmt.Sprintf("unexpected %s after end of expression", describeToken(start)),
+			Span:     Span{Start: start.Start, End: end.End},
+			Expected: []TokenType{EOF},
+		})
+	}
+	return exp
+}
+
 // ParseExpression parses a complete expression
 func (p *Parser) ParseExpression() Expression {
 	return p.parseOrExpression()
@@ -15,12 +47,7 @@ func (p *Parser) parseOrExpression() Expression {
 		token := p.currentToken
 		p.nextToken()
 		right := p.parseAndExpression()
-		left = &BinaryExpression{
-			Token:    token,
-			Left:     left,
-			Operator: token.Literal,
-			Right:    right,
-		}
+		left = newBinaryExpression(token, left, right)
 	}
 	return left
 }
@@ -33,12 +60,65 @@ func (p *Parser) parseAndExpression() Expression {
 		token := p.currentToken
 		p.nextToken()
 		right := p.parseComparisonExpression()
-		left = &BinaryExpression{
-			Token:    token,
-			Left:     left,
-			Operator: token.Literal,
-			Right:    right,
+		left = newBinaryExpression(token, left, right)
+	}
+	return left
+}
+
+// parseDefaultExpression handles fallback chains like @nickname ?? @name ?? 'guest'
+func (p *Parser) parseDefaultExpression() Expression {
+	left := p.parseAdditiveExpression()
+	if !p.currentTokenIs(COALESCE) {
+		return left
+	}
+
+	de := &DefaultExpression{Token: p.currentToken, Operands: []Expression{left}}
+	for p.currentTokenIs(COALESCE) {
+		p.nextToken()
+		de.Operands = append(de.Operands, p.parseAdditiveExpression())
+	}
+	de.Span = Span{
+		Start: spanOf(left, de.Token).Start,
+		End:   spanOf(de.Operands[len(de.Operands)-1], de.Token).End,
+	}
+
+	p.checkDefaultOperands(de)
+	return de
+}
+
+// checkDefaultOperands reports fallbacks for anything but a variable, since
+// only variables can be missing
+func (p *Parser) checkDefaultOperands(de *DefaultExpression) {
+	for _, operand := range de.Operands[:len(de.Operands)-1] {
+		if _, ok := operand.(*Variable); !ok && operand != nil {
+			p.addErrorSpan(operand.Location(), E_INVALID_EXPRESSION, "only a variable can have a default value")
 		}
 	}
+}
+
+// parseAdditiveExpression handles + and - operations
+func (p *Parser) parseAdditiveExpression() Expression {
+	left := p.parseMultiplicativeExpression()
+
+	for p.isAdditiveOperator(p.currentToken.Type) {
+		token := p.currentToken
+		p.nextToken()
+		operands := []Expression{left, p.parseMultiplicativeExpression()}
+		p.coerceDates(token.Type, operands)
+		left = newBinaryExpression(token, operands[0], operands[1])
+	}
+	return left
+}
+
+// parseMultiplicativeExpression handles *, / and % operations
+func (p *Parser) parseMultiplicativeExpression() Expression {
+	left := p.parsePrimaryExpression()
+
+	for p.isMultiplicativeOperator(p.currentToken.Type) {
+		token := p.currentToken
+		p.nextToken()
+		right := p.parsePrimaryExpression()
+		left = newBinaryExpression(token, left, right)
+	}
 	return left
 }
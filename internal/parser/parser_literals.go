@@ -1,8 +1,14 @@
 package parser
 
+import (
+	"fmt"
+	"strings"
+)
+
 // This file contains the literal parsing logic for the parser
 
-// parseArrayLiteral handles array literals like [1, 2, 3]
+// parseArrayLiteral handles array literals like [1, 2, 3]. Like call
+// arguments, the elements are full expressions.
 func (p *Parser) parseArrayLiteral() Expression {
 	// Create array literal node
 	array := &ArrayLiteral{Token: p.currentToken}
@@ -12,17 +18,13 @@ func (p *Parser) parseArrayLiteral() Expression {
 
 	// Handle empty array
 	if p.currentTokenIs(RBRACKET) {
+		array.Span = Span{Start: array.Token.Start, End: p.currentToken.End}
 		p.nextToken() // consume ]
 		return array
 	}
 
 	// Parse first element
-	elem := p.parsePrimaryExpression()
-	if elem == nil {
-		p.addError("invalid array element")
-		return nil
-	}
-	array.Elements = append(array.Elements, elem)
+	array.Elements = append(array.Elements, p.ParseExpression())
 
 	// Parse remaining elements
 	for p.currentTokenIs(COMMA) {
@@ -33,47 +35,115 @@ func (p *Parser) parseArrayLiteral() Expression {
 			break
 		}
 
-		elem = p.parsePrimaryExpression()
-		if elem == nil {
-			p.addError("invalid array element after comma")
-			return nil
-		}
-		array.Elements = append(array.Elements, elem)
+		array.Elements = append(array.Elements, p.ParseExpression())
 	}
 
 	if !p.currentTokenIs(RBRACKET) {
-		p.addErrorf("expected right bracket, got %s", p.currentToken.Type)
-		return nil
+		p.addExpectedError(fmt.Sprintf("expected right bracket, got %s", p.currentToken.Type), RBRACKET)
+		return p.recoverUntilClosing(array.Token, RBRACKET)
 	}
+	array.Span = Span{Start: array.Token.Start, End: p.currentToken.End}
 	p.nextToken() // consume ]
 	return array
 }
 
-// parseFunctionCall handles function calls like LOG(x)
+// parseFunctionCall handles function calls like LOG(x) and MAX(@a, @b)
 func (p *Parser) parseFunctionCall() Expression {
 	fc := &FunctionCall{
 		Token:    p.currentToken,
-		Function: p.currentToken.Literal,
+		Function: strings.ToUpper(p.currentToken.Literal),
+	}
+
+	args, ok := p.parseCallArguments()
+	if !ok {
+		return p.recoverUntilClosing(fc.Token, RPAREN)
+	}
+	fc.Arguments = args
+	fc.Span = Span{Start: fc.Token.Start, End: p.currentToken.End}
+	p.nextToken()
+
+	p.checkFunctionCall(fc)
+	return fc
+}
+
+// parseDefaultCall handles DEFAULT(@nickname, @name, 'guest'), the call form
+// of @nickname ?? @name ?? 'guest'
+func (p *Parser) parseDefaultCall() Expression {
+	de := &DefaultExpression{Token: p.currentToken}
+
+	args, ok := p.parseCallArguments()
+	if !ok {
+		return p.recoverUntilClosing(de.Token, RPAREN)
 	}
+	de.Operands = args
+	de.Span = Span{Start: de.Token.Start, End: p.currentToken.End}
+
+	if len(args) < 2 {
+		p.addErrorSpan(de.Span, E_ARGUMENT_COUNT, fmt.Sprintf("DEFAULT expects at least 2 arguments, got %d", len(args)))
+	} else {
+		p.checkDefaultOperands(de)
+	}
+	p.nextToken()
+	return de
+}
+
+// parseCallArguments parses the parenthesised arguments that follow the name
+// of a call. On success the current token is the closing parenthesis;
+// otherwise the error has been reported.
+func (p *Parser) parseCallArguments() ([]Expression, bool) {
 	p.nextToken() // move to '('
 	p.nextToken() // move past '('
 
-	fc.Arguments = []Expression{}
-	for !p.currentTokenIs(RPAREN) {
-		arg := p.ParseExpression()
-		if arg != nil {
-			fc.Arguments = append(fc.Arguments, arg)
-		}
+	args := []Expression{}
+	for !p.currentTokenIs(RPAREN) && !p.currentTokenIs(EOF) {
+		args = append(args, p.ParseExpression())
 
-		if p.currentTokenIs(COMMA) {
-			p.nextToken()
+		if !p.currentTokenIs(COMMA) {
+			break
 		}
+		p.nextToken()
 	}
 
 	if !p.currentTokenIs(RPAREN) {
-		p.addError("expected right parenthesis")
-		return nil
+		p.addExpectedError("expected right parenthesis", RPAREN)
+		return nil, false
+	}
+	return args, true
+}
+
+// checkFunctionCall resolves the function a call refers to and checks its arguments
+func (p *Parser) checkFunctionCall(fc *FunctionCall) {
+	fn, ok := p.registry.Function(fc.Function)
+	if !ok {
+		p.addErrorAt(fc.Token, E_UNKNOWN_FUNCTION, fmt.Sprintf("unknown function %s", fc.Function))
+		return
+	}
+	fc.Definition = fn
+	p.checkArguments(fc, fn)
+}
+
+// checkArguments reports arguments that do not fit the signature of fn
+func (p *Parser) checkArguments(fc *FunctionCall, fn *Function) {
+	if msg := fn.checkArity(len(fc.Arguments)); msg != "" {
+		p.addErrorSpan(fc.Span, E_ARGUMENT_COUNT, msg)
+		return
+	}
+	for i, arg := range fc.Arguments {
+		want, got := fn.paramType(i), staticType(arg)
+		if want != TYPE_ANY && got != TYPE_ANY && want != got {
+			p.addErrorSpan(arg.Location(), E_ARGUMENT_TYPE,
+				fmt.Sprintf("argument %d of %s must be %s, got %s", i+1, fn.Name, want, got))
+		}
+	}
+
+	// A literal pattern or date is checked now, so a typo is reported with
+	// the rule rather than on every evaluation
+	if fn.literal != nil && len(fc.Arguments) > 0 {
+		arg := fc.Arguments[len(fc.Arguments)-1]
+		if value, ok := stringLiteral(arg); ok {
+			if err := fn.literal(value); err != nil {
+				p.addErrorSpan(arg.Location(), fn.literalCode, err.Error())
+			}
+		}
 	}
-	p.nextToken()
-	return fc
 }
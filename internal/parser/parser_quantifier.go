@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file contains the parsing of array quantifiers
+
+// parseQuantifierExpression handles ANY|ALL|NONE collection AS name: body.
+// Like the ELSE of an IF, the body extends as far right as possible.
+func (p *Parser) parseQuantifierExpression() Expression {
+	qe := &QuantifierExpression{Token: p.currentToken}
+	keyword := string(qe.Token.Type)
+	p.nextToken() // consume ANY, ALL or NONE
+
+	qe.Collection = p.parseDefaultExpression()
+	if !p.currentTokenIs(AS) {
+		p.addExpectedError("expected AS after "+keyword+" collection", AS)
+		return p.recoverFrom(qe.Token)
+	}
+	p.nextToken() // consume AS
+
+	if !p.currentTokenIs(IDENTIFIER) || strings.ContainsAny(p.currentToken.Literal, ".[") {
+		p.addExpectedError("expected a name for the element after AS", IDENTIFIER)
+		return p.recoverFrom(qe.Token)
+	}
+	qe.Element = p.currentToken.Literal
+	p.nextToken() // consume the name
+
+	if !p.currentTokenIs(COLON) {
+		p.addExpectedError(fmt.Sprintf("expected ':' after AS %s", qe.Element), COLON)
+		return p.recoverFrom(qe.Token)
+	}
+	p.nextToken() // consume ':'
+
+	p.pushScope(qe.Token.Type, binding{name: qe.Element})
+	qe.Body = p.ParseExpression()
+	p.popScope()
+
+	qe.Span = Span{Start: qe.Token.Start, End: spanOf(qe.Body, qe.Token).End}
+	return qe
+}
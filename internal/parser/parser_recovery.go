@@ -0,0 +1,56 @@
+package parser
+
+// This file contains the error recovery logic for the parser.
+//
+// The parser uses panic-mode recovery: after reporting an error it discards
+// tokens until it reaches a synchronisation point (a token that can legally
+// follow a sub-expression), records the discarded input as a BadExpression and
+// resumes parsing from there. Every loop in the parser either consumes a token
+// or stops at a synchronisation point, so parsing always terminates.
+
+// isSyncToken reports whether parsing can resume at the given token type
+func isSyncToken(t TokenType) bool {
+	switch t {
+	case COMMA, RPAREN, RBRACKET, AND, OR, THEN, ELSE, WHEN, END, EOF:
+		return true
+	default:
+		return false
+	}
+}
+
+// recoverFrom skips tokens up to the next synchronisation point and returns a
+// BadExpression covering the input from start to the last skipped token.
+func (p *Parser) recoverFrom(start Token) *BadExpression {
+	bad := &BadExpression{Token: start, Span: start.Span()}
+	for !isSyncToken(p.currentToken.Type) {
+		bad.Span.End = p.currentToken.End
+		p.nextToken()
+	}
+	return bad
+}
+
+// recoverUntilClosing recovers from an unclosed delimiter opened by the given
+// token. Commas, AND and OR inside the delimiters belong to it, so it skips
+// to the matching closing token, passing over nested brackets, and consumes
+// it so the enclosing expression can carry on normally. Any other
+// synchronisation point ends the recovery without consuming it.
+func (p *Parser) recoverUntilClosing(open Token, closing TokenType) *BadExpression {
+	bad := &BadExpression{Token: open, Span: open.Span()}
+	depth := 0
+	for {
+		switch t := p.currentToken.Type; {
+		case depth == 0 && t == closing:
+			bad.Span.End = p.currentToken.End
+			p.nextToken()
+			return bad
+		case t == LPAREN || t == LBRACKET:
+			depth++
+		case depth > 0 && (t == RPAREN || t == RBRACKET):
+			depth--
+		case depth == 0 && t != COMMA && t != AND && t != OR && isSyncToken(t), t == EOF:
+			return bad
+		}
+		bad.Span.End = p.currentToken.End
+		p.nextToken()
+	}
+}
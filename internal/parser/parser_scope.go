@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file contains the tracking of the names quantifiers and lambdas bring
+// into scope
+
+// scope holds the names the body of a quantifier or lambda can refer to.
+// JSONLogic evaluates such a body against data of its own, so neither
+// @variables nor the names of enclosing bodies are visible inside it.
+type scope struct {
+	keyword  TokenType // The ANY, ALL, NONE, FILTER, MAP or REDUCE token type
+	bindings []binding
+}
+
+// binding is a name in scope and the key the body's data holds its value
+// under, e.g. current for the element of REDUCE. An empty key means the
+// value is the data itself.
+type binding struct {
+	name string
+	key  string
+}
+
+// pushScope brings names into scope for the body about to be parsed
+func (p *Parser) pushScope(keyword TokenType, bindings ...binding) {
+	p.scopes = append(p.scopes, scope{keyword: keyword, bindings: bindings})
+}
+
+// popScope ends the innermost scope
+func (p *Parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// lookupBinding returns the binding of name in the innermost scope
+func (p *Parser) lookupBinding(name string) (binding, bool) {
+	if len(p.scopes) == 0 {
+		return binding{}, false
+	}
+	for _, b := range p.scopes[len(p.scopes)-1].bindings {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+// boundOutside reports whether name is bound by an enclosing scope
+func (p *Parser) boundOutside(name string) bool {
+	for _, s := range p.scopes {
+		for _, b := range s.bindings {
+			if b.name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkScope reports a variable used inside a body that cannot see it
+func (p *Parser) checkScope(tok Token, name string) {
+	if len(p.scopes) == 0 {
+		return
+	}
+	inner := p.scopes[len(p.scopes)-1]
+	names := make([]string, len(inner.bindings))
+	for i, b := range inner.bindings {
+		names[i] = b.name
+	}
+	verb := "is"
+	if len(names) > 1 {
+		verb = "are"
+	}
+	p.addErrorAt(tok, E_INVALID_VARIABLE, fmt.Sprintf("%s cannot be used inside %s; only %s %s in scope", name, inner.keyword, strings.Join(names, " and "), verb))
+}
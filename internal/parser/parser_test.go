@@ -2,6 +2,7 @@ package parser
 
 import (
 	"encoding/json"
+	"math"
 	"testing"
 )
 
@@ -42,6 +43,254 @@ func TestParser(t *testing.T) {
 			input:    "(@age > 18) AND (@score >= 75)",
 			expected: `{"and": [{">": [{"var": "age"}, 18]}, {">=": [{"var": "score"}, 75]}]}`,
 		},
+		{
+			input:    "@price * @qty > 100",
+			expected: `{">": [{"*": [{"var": "price"}, {"var": "qty"}]}, 100]}`,
+		},
+		{
+			input:    "@a + @b * @c - 1",
+			expected: `{"-": [{"+": [{"var": "a"}, {"*": [{"var": "b"}, {"var": "c"}]}]}, 1]}`,
+		},
+		{
+			input:    "(@a + @b) % 2 == 0",
+			expected: `{"==": [{"%": [{"+": [{"var": "a"}, {"var": "b"}]}, 2]}, 0]}`,
+		},
+		{
+			input:    "@total / 4 >= -5",
+			expected: `{">=": [{"/": [{"var": "total"}, 4]}, -5]}`,
+		},
+		{
+			input:    "-@delta < 0",
+			expected: `{"<": [{"-": [{"var": "delta"}]}, 0]}`,
+		},
+		{
+			input:    "@isActive === true AND @n !== '5'",
+			expected: `{"and": [{"===": [{"var": "isActive"}, true]}, {"!==": [{"var": "n"}, "5"]}]}`,
+		},
+		{
+			input:    "@isDeleted == FALSE OR @deletedAt != null",
+			expected: `{"or": [{"==": [{"var": "isDeleted"}, false]}, {"!=": [{"var": "deletedAt"}, null]}]}`,
+		},
+		{
+			input:    "@flag IN [true, null]",
+			expected: `{"in": [{"var": "flag"}, [true, null]]}`,
+		},
+		{
+			input:    "@user.address.city == 'Pune'",
+			expected: `{"==": [{"var": "user.address.city"}, "Pune"]}`,
+		},
+		{
+			input:    "@items[0].price * @items[0].qty > 100",
+			expected: `{">": [{"*": [{"var": "items.0.price"}, {"var": "items.0.qty"}]}, 100]}`,
+		},
+		{
+			input:    `@user["first-name"] IN ['Ann', 'Bo'] AND @user['last name'] != null`,
+			expected: `{"and": [{"in": [{"var": "user.first-name"}, ["Ann", "Bo"]]}, {"!=": [{"var": "user.last name"}, null]}]}`,
+		},
+		{
+			input:    `@user['a]b'] == 1 AND @map["[x]"][0] == 2`,
+			expected: `{"and": [{"==": [{"var": "user.a]b"}, 1]}, {"==": [{"var": "map.[x].0"}, 2]}]}`,
+		},
+		{
+			input:    "@matrix[1][2] == @rows.1.2",
+			expected: `{"==": [{"var": "matrix.1.2"}, {"var": "rows.1.2"}]}`,
+		},
+		{
+			input:    "@age ?? 0 >= 18",
+			expected: `{">=": [{"var": ["age", 0]}, 18]}`,
+		},
+		{
+			input:    "DEFAULT(@user.country, 'IN') == 'IN'",
+			expected: `{"==": [{"var": ["user.country", "IN"]}, "IN"]}`,
+		},
+		{
+			input:    "@nickname ?? @name ?? 'guest'",
+			expected: `{"if": [{"!": [{"missing": ["nickname"]}]}, {"var": "nickname"}, {"var": ["name", "guest"]}]}`,
+		},
+		{
+			input:    "@discount ?? @base * 2 + 1",
+			expected: `{"var": ["discount", {"+": [{"*": [{"var": "base"}, 2]}, 1]}]}`,
+		},
+		{
+			input:    "@email IS MISSING",
+			expected: `{"missing": ["email"]}`,
+		},
+		{
+			input:    "EXISTS @user.email AND @user.phone is not missing",
+			expected: `{"and": [{"!": [{"missing": ["user.email"]}]}, {"!": [{"missing": ["user.phone"]}]}]}`,
+		},
+		{
+			input:    "NOT MISSING_SOME(1, [@email, @phone])",
+			expected: `{"!": [{"missing_some": [1, ["email", "phone"]]}]}`,
+		},
+		{
+			input:    "IF @vip THEN 0.2 ELSE 0.05",
+			expected: `{"if": [{"var": "vip"}, 0.2, 0.05]}`,
+		},
+		{
+			input:    "@total * (IF @vip THEN 0.8 ELSE 1) > 100",
+			expected: `{">": [{"*": [{"var": "total"}, {"if": [{"var": "vip"}, 0.8, 1]}]}, 100]}`,
+		},
+		{
+			input:    "IF @a THEN 1 ELSE IF @b THEN 2 ELSE 3",
+			expected: `{"if": [{"var": "a"}, 1, {"if": [{"var": "b"}, 2, 3]}]}`,
+		},
+		{
+			input:    "case when @total >= 1000 then 'gold' when @total >= 500 then 'silver' else 'bronze' end",
+			expected: `{"if": [{">=": [{"var": "total"}, 1000]}, "gold", {">=": [{"var": "total"}, 500]}, "silver", "bronze"]}`,
+		},
+		{
+			input:    "CASE WHEN @country IN ['IN', 'LK'] THEN 'apac' END == 'apac'",
+			expected: `{"==": [{"if": [{"in": [{"var": "country"}, ["IN", "LK"]]}, "apac"]}, "apac"]}`,
+		},
+		{
+			input:    "ANY @cart AS item: item.price > 100",
+			expected: `{"some": [{"var": "cart"}, {">": [{"var": "price"}, 100]}]}`,
+		},
+		{
+			input:    "all @tags as tag: tag != 'spam' AND tag != ''",
+			expected: `{"all": [{"var": "tags"}, {"and": [{"!=": [{"var": ""}, "spam"]}, {"!=": [{"var": ""}, ""]}]}]}`,
+		},
+		{
+			input:    "(NONE @orders AS o: ANY o.lines AS line: line['unit price'] * line.qty > 500) AND @vip",
+			expected: `{"and": [{"none": [{"var": "orders"}, {"some": [{"var": "lines"}, {">": [{"*": [{"var": "unit price"}, {"var": "qty"}]}, 500]}]}]}, {"var": "vip"}]}`,
+		},
+		{
+			input:    "ANY @matrix ?? [] AS row: row[0] == 1",
+			expected: `{"some": [{"var": ["matrix", []]}, {"==": [{"var": "0"}, 1]}]}`,
+		},
+		{
+			input:    "FILTER(@orders, o -> o.status == 'paid')",
+			expected: `{"filter": [{"var": "orders"}, {"==": [{"var": "status"}, "paid"]}]}`,
+		},
+		{
+			input:    "map(@orders, (o) -> o.total * 2)",
+			expected: `{"map": [{"var": "orders"}, {"*": [{"var": "total"}, 2]}]}`,
+		},
+		{
+			input:    "REDUCE(FILTER(@orders, o -> o.status == 'paid'), (sum, o) -> sum + o.total, 0) > 500",
+			expected: `{">": [{"reduce": [{"filter": [{"var": "orders"}, {"==": [{"var": "status"}, "paid"]}]}, {"+": [{"var": "accumulator"}, {"var": "current.total"}]}, 0]}, 500]}`,
+		},
+		{
+			input:    "SUM(MAP(@orders, o -> o.total)) > 500",
+			expected: `{">": [{"reduce": [{"map": [{"var": "orders"}, {"var": "total"}]}, {"+": [{"var": "current"}, {"var": "accumulator"}]}, 0]}, 500]}`,
+		},
+		{
+			input:    "MERGE(@tags, ['new'], 'sale')",
+			expected: `{"merge": [{"var": "tags"}, ["new"], "sale"]}`,
+		},
+		{
+			input:    "ANY MAP(@orders, o -> o.lines) AS lines: ANY lines AS l: l.qty > 1",
+			expected: `{"some": [{"map": [{"var": "orders"}, {"var": "lines"}]}, {"some": [{"var": ""}, {">": [{"var": "qty"}, 1]}]}]}`,
+		},
+		{
+			input:    "@email ENDS_WITH '@example.com' AND @sku STARTS_WITH 'ABC-'",
+			expected: `{"and": [{"==": [{"substr": [{"var": "email"}, -12]}, "@example.com"]}, {"==": [{"substr": [{"var": "sku"}, 0, 4]}, "ABC-"]}]}`,
+		},
+		{
+			input:    `@email MATCHES '^.+@corp\.com$' AND @name like 'Jo%'`,
+			expected: `{"and": [{"matches": [{"var": "email"}, "^.+@corp\\.com$"]}, {"like": [{"var": "name"}, "Jo%"]}]}`,
+		},
+		{
+			input:    "@createdAt > NOW() - 30d AND @expiresAt <= DATE('2025-01-01') + 1h30m",
+			expected: `{"and": [{">": [{"date": [{"var": "createdAt"}]}, {"-": [{"now": []}, 2592000000]}]}, {"<=": [{"date": [{"var": "expiresAt"}]}, {"+": [{"date": ["2025-01-01"]}, 5400000]}]}]}`,
+		},
+		{
+			input:    "NOW() - @renewedAt > 2w AND @trialEnd + 500ms BETWEEN '2025-01-01' AND NOW()",
+			expected: `{"and": [{">": [{"-": [{"now": []}, {"date": [{"var": "renewedAt"}]}]}, 1209600000]}, {"<=": [{"date": ["2025-01-01"]}, {"+": [{"date": [{"var": "trialEnd"}]}, 500]}, {"now": []}]}]}`,
+		},
+		{
+			input:    "@age > 18 - 1 AND @ttl > 1.5s",
+			expected: `{"and": [{">": [{"var": "age"}, {"-": [18, 1]}]}, {">": [{"var": "ttl"}, 1500]}]}`,
+		},
+		{
+			input:    "@n >= 1_000_000 AND @mask == 0x1F",
+			expected: `{"and": [{">=": [{"var": "n"}, 1000000]}, {"==": [{"var": "mask"}, 31]}]}`,
+		},
+		{
+			input:    "[1.5e-3, - 3, -0.5E2, -@g]",
+			expected: `[0.0015, -3, -50, {"-": [{"var": "g"}]}]`,
+		},
+		{
+			input:    "@straße.größe > 40 AND @名前 == '太郎' AND ANY @注文 AS 項目: 項目.数量 > 1",
+			expected: `{"and": [{"and": [{">": [{"var": "straße.größe"}, 40]}, {"==": [{"var": "名前"}, "太郎"]}]}, {"some": [{"var": "注文"}, {">": [{"var": "数量"}, 1]}]}]}`,
+		},
+		{
+			input:    `@a == 'it\'s' AND @b == "tab\tand\nline \"q\" \\ \/"`,
+			expected: `{"and": [{"==": [{"var": "a"}, "it's"]}, {"==": [{"var": "b"}, "tab\tand\nline \"q\" \\ /"]}]}`,
+		},
+		{
+			input:    `['\u00e9', '\u{1F600}', '\uD83D\uDE00', 'caf\u00E9!']`,
+			expected: `["é", "😀", "😀", "café!"]`,
+		},
+		{
+			input:    "@code MATCHES `^\\d{3}-\\w+$` AND @path == `C:\\temp\\new`",
+			expected: `{"and": [{"matches": [{"var": "code"}, "^\\d{3}-\\w+$"]}, {"==": [{"var": "path"}, "C:\\temp\\new"]}]}`,
+		},
+		{
+			input:    "@name starts_with 'Zoë'",
+			expected: `{"==": [{"substr": [{"var": "name"}, 0, 3]}, "Zoë"]}`,
+		},
+		{
+			input:    "@x STARTS_WITH '😀' AND @x ENDS_WITH 'é😀!'",
+			expected: `{"and": [{"==": [{"substr": [{"var": "x"}, 0, 2]}, "😀"]}, {"==": [{"substr": [{"var": "x"}, -4]}, "é😀!"]}]}`,
+		},
+		{
+			input:    "@title CONTAINS 'admin' OR 'root' IN @title",
+			expected: `{"or": [{"in": ["admin", {"var": "title"}]}, {"in": ["root", {"var": "title"}]}]}`,
+		},
+		{
+			input:    "'admin' NOT IN @roles",
+			expected: `{"!": [{"in": ["admin", {"var": "roles"}]}]}`,
+		},
+		{
+			input:    "CAT(@first, ' ', @last) == SUBSTR(@name, 0, -1)",
+			expected: `{"==": [{"cat": [{"var": "first"}, " ", {"var": "last"}]}, {"substr": [{"var": "name"}, 0, -1]}]}`,
+		},
+		{
+			input:    "MAX(@a, @b, 10) > 50",
+			expected: `{">": [{"max": [{"var": "a"}, {"var": "b"}, 10]}, 50]}`,
+		},
+		{
+			input:    "min([3, @floor])",
+			expected: `{"min": [3, {"var": "floor"}]}`,
+		},
+		{
+			input:    "MAX(@scores)",
+			expected: `{"reduce": [{"var": "scores"}, {"if": [{"==": [{"var": "accumulator"}, null]}, {"var": "current"}, {"max": [{"var": "current"}, {"var": "accumulator"}]}]}, null]}`,
+		},
+		{
+			input:    "SUM(@a, @b)",
+			expected: `{"+": [{"var": "a"}, {"var": "b"}]}`,
+		},
+		{
+			input:    "SUM(@items)",
+			expected: `{"reduce": [{"var": "items"}, {"+": [{"var": "current"}, {"var": "accumulator"}]}, 0]}`,
+		},
+		{
+			input:    "AVG(@a, @b, @c) >= 75",
+			expected: `{">=": [{"/": [{"+": [{"var": "a"}, {"var": "b"}, {"var": "c"}]}, 3]}, 75]}`,
+		},
+		{
+			input:    "AVG(@scores)",
+			expected: `{"/": [{"reduce": [{"var": "scores"}, {"+": [{"var": "current"}, {"var": "accumulator"}]}, 0]}, {"reduce": [{"var": "scores"}, {"+": [{"var": "accumulator"}, 1]}, 0]}]}`,
+		},
+		{
+			input:    "@age BETWEEN 18 AND 65",
+			expected: `{"<=": [18, {"var": "age"}, 65]}`,
+		},
+		{
+			input:    "@age between 18 and 65 AND @active",
+			expected: `{"and": [{"<=": [18, {"var": "age"}, 65]}, {"var": "active"}]}`,
+		},
+		{
+			input:    "@score NOT BETWEEN @min AND @max - 1",
+			expected: `{"!": [{"<=": [{"var": "min"}, {"var": "score"}, {"-": [{"var": "max"}, 1]}]}]}`,
+		},
+		{
+			input:    "@temp BETWEEN 0 AND 100 EXCLUSIVE OR @override",
+			expected: `{"or": [{"<": [0, {"var": "temp"}, 100]}, {"var": "override"}]}`,
+		},
 	}
 
 	for i, tt := range tests {
@@ -80,3 +329,292 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+func TestParserNumberValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"42", int64(42)},
+		{"9007199254740993", int64(9007199254740993)},
+		{"-9223372036854775808", int64(math.MinInt64)},
+		{"0xff_ff", int64(65535)},
+		{"1_000.5", 1000.5},
+		{"2E3", float64(2000)},
+		{"1.", float64(1)},
+		{"18446744073709551616", json.Number("18446744073709551616")},
+		{"-9223372036854775809", json.Number("-9223372036854775809")},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - ParseProgram() failed for %q. Errors: %v", i, tt.input, p.Errors())
+			continue
+		}
+
+		lit, ok := expression.(*Literal)
+		if !ok || lit.Value != tt.expected {
+			t.Errorf("test[%d] - wrong value for %q. got=%#v, want=%#v", i, tt.input, expression, tt.expected)
+		}
+	}
+}
+
+func TestParserSpans(t *testing.T) {
+	input := "@a > 1 AND @role NOT IN ['x']"
+	p := NewParser(NewLexer(input))
+
+	expression := p.ParseExpression()
+	if expression == nil {
+		t.Fatalf("ParseExpression() returned nil. Errors: %v", p.Errors())
+	}
+
+	and, ok := expression.(*BinaryExpression)
+	if !ok {
+		t.Fatalf("expected *BinaryExpression, got %T", expression)
+	}
+
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{and, "@a > 1 AND @role NOT IN ['x']"},
+		{and.Left, "@a > 1"},
+		{and.Left.(*BinaryExpression).Right, "1"},
+		{and.Right, "@role NOT IN ['x']"},
+		{and.Right.(*UnaryExpression).Right.(*BinaryExpression).Right, "['x']"},
+	}
+
+	for i, tt := range tests {
+		span := tt.node.Location()
+		if got := input[span.Start.Offset:span.End.Offset]; got != tt.expected {
+			t.Errorf("test[%d] - wrong span text. got=%q, want=%q", i, got, tt.expected)
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	p := NewParser(NewLexer("@a > 1 AND\n  (@b > 2"))
+	p.ParseExpression()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected errors, got none")
+	}
+	if expected := "2:10: expected right parenthesis"; errors[0] != expected {
+		t.Errorf("wrong error. got=%q, want=%q", errors[0], expected)
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	p := NewParser(NewLexer("@status IN ['a', 'b'"))
+	p.ParseExpression()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics, got none")
+	}
+
+	d := diagnostics[0]
+	if d.Severity != SeverityError || d.Code != E_EXPECTED_TOKEN {
+		t.Errorf("wrong diagnostic. got=%s %s, want=%s %s", d.Severity, d.Code, SeverityError, E_EXPECTED_TOKEN)
+	}
+	if len(d.Expected) != 1 || d.Expected[0] != RBRACKET {
+		t.Errorf("wrong expected tokens. got=%v, want=[%s]", d.Expected, RBRACKET)
+	}
+	if d.Span.Start.Offset != 20 {
+		t.Errorf("wrong span start. got=%d, want=20", d.Span.Start.Offset)
+	}
+	if d.Fix == nil || d.Fix.Replacement != "]" {
+		t.Errorf("expected fix inserting ']', got %+v", d.Fix)
+	}
+}
+
+func TestParseProgramRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode DiagnosticCode
+		expectedMsg  string
+	}{
+		{"@age > 18 foo bar", E_TRAILING_INPUT, "1:11: unexpected IDENTIFIER 'foo' after end of expression"},
+		{"@a > 1 )", E_TRAILING_INPUT, "1:8: unexpected ')' after end of expression"},
+		{"@name == 'John", E_UNTERMINATED_STRING, "1:10: unterminated string literal"},
+		{"@a > 1 /* note", E_UNTERMINATED_COMMENT, "1:8: unterminated block comment"},
+		{"@a > 1 $", E_ILLEGAL_CHARACTER, "1:8: illegal character \"$\""},
+		{"@a > $", E_ILLEGAL_CHARACTER, "1:6: illegal character \"$\""},
+		{"@age BETWEEN 18 OR 65", E_EXPECTED_TOKEN, "1:17: expected AND between the bounds of BETWEEN"},
+		{"@ > 1", E_INVALID_VARIABLE, "1:2: expected a variable name after '@'"},
+		{"@items[0 > 1", E_INVALID_VARIABLE, "1:7: expected ']' in variable path"},
+		{"@user['a.b'] > 1", E_INVALID_VARIABLE, "1:6: key \"a.b\" cannot be used in a variable path"},
+		{"@user['name > 1", E_UNTERMINATED_STRING, "1:6: unterminated key in variable path"},
+		{"@a + 1 ?? 0", E_INVALID_EXPRESSION, "1:1: only a variable can have a default value"},
+		{"DEFAULT(@a) > 1", E_ARGUMENT_COUNT, "1:1: DEFAULT expects at least 2 arguments, got 1"},
+		{"@a ?", E_ILLEGAL_CHARACTER, "1:4: illegal character \"?\""},
+		{"@a + 1 IS MISSING", E_INVALID_EXPRESSION, "1:1: only a variable can be checked with IS MISSING"},
+		{"@a IS NULL", E_EXPECTED_TOKEN, "1:7: expected MISSING after IS"},
+		{"EXISTS 'a'", E_EXPECTED_TOKEN, "1:8: expected a variable after EXISTS"},
+		{"MISSING_SOME(1, [@a, 'b'])", E_INVALID_EXPRESSION, "1:22: MISSING_SOME expects an array of variables"},
+		{"IF @a ELSE 2", E_EXPECTED_TOKEN, "1:7: expected THEN after IF condition"},
+		{"CASE WHEN @a THEN 1", E_EXPECTED_TOKEN, "1:20: expected END to close CASE"},
+		{"CASE", E_EXPECTED_TOKEN, "1:5: expected WHEN after CASE"},
+		{"ANY @cart item.price > 100", E_EXPECTED_TOKEN, "1:11: expected AS after ANY collection"},
+		{"ANY @cart AS 'item': true", E_EXPECTED_TOKEN, "1:14: expected a name for the element after AS"},
+		{"ALL @cart AS item item.price > 100", E_EXPECTED_TOKEN, "1:19: expected ':' after AS item"},
+		{"ANY @cart AS item: item.price > @limit", E_INVALID_VARIABLE, "1:33: @limit cannot be used inside ANY; only item is in scope"},
+		{"ANY @orders AS o: ALL o.lines AS l: l.qty > o.min", E_INVALID_VARIABLE, "1:45: o cannot be used inside ALL; only l is in scope"},
+		{"item.price > 100", E_INVALID_VARIABLE, "1:1: item is not in scope"},
+		{"FILTER(@orders o -> o.paid)", E_EXPECTED_TOKEN, "1:16: expected ',' after the FILTER collection"},
+		{"MAP(@orders, 'total')", E_EXPECTED_TOKEN, "1:14: expected a lambda such as o -> o.total"},
+		{"MAP(@orders, o => o.total)", E_EXPECTED_TOKEN, "1:16: expected '->' after the lambda parameters"},
+		{"MAP(@orders, (a, b) -> a)", E_ARGUMENT_COUNT, "1:14: the MAP lambda takes 1 parameter, got 2"},
+		{"REDUCE(@orders, o -> o.total, 0)", E_ARGUMENT_COUNT, "1:17: the REDUCE lambda takes 2 parameters, got 1"},
+		{"REDUCE(@orders, (o, o) -> o, 0)", E_INVALID_EXPRESSION, "1:17: duplicate lambda parameter o"},
+		{"REDUCE(@orders, (sum, o) -> sum + o.total)", E_EXPECTED_TOKEN, "1:42: expected ',' and the initial value after the REDUCE lambda"},
+		{"REDUCE(@orders, (sum, o) -> sum + @tax, 0)", E_INVALID_VARIABLE, "1:35: @tax cannot be used inside REDUCE; only sum and o are in scope"},
+		{"FILTER > 1", E_EXPECTED_TOKEN, "1:1: expected '(' after FILTER"},
+		{"@sku STARTS_WITH @prefix", E_ARGUMENT_TYPE, "1:18: STARTS_WITH expects a string literal"},
+		{"@email MATCHES '^(.+@corp'", E_INVALID_PATTERN, "1:16: invalid regular expression: missing closing ): `^(.+@corp`"},
+		{"@name LIKE 'Jo\\\\'", E_INVALID_PATTERN, "1:12: invalid LIKE pattern: trailing backslash"},
+		{`@a == '\d' AND @b MATCHES '\d'`, E_INVALID_ESCAPE, `1:8: invalid escape sequence \d`},
+		{"@name MATCHES 42", E_ARGUMENT_TYPE, "1:15: argument 2 of MATCHES must be string, got number"},
+		{"@name == 'J\xffrg'", E_INVALID_UTF8, "1:12: invalid UTF-8 byte 0xff"},
+		{"@größe > 1 \xe4", E_INVALID_UTF8, "1:12: invalid UTF-8 byte 0xe4"},
+		{`@a == 'x\qy'`, E_INVALID_ESCAPE, `1:9: invalid escape sequence \q`},
+		{`@a == '\u12G4'`, E_INVALID_ESCAPE, `1:8: invalid Unicode escape \u12: expected 4 hex digits`},
+		{`@a == "\uD83D!"`, E_INVALID_ESCAPE, `1:8: invalid Unicode escape \uD83D: unpaired surrogate`},
+		{`@a == '\u{110000}'`, E_INVALID_ESCAPE, `1:8: invalid Unicode escape \u{110000}: not a Unicode character`},
+		{"@a MATCHES `^\\d+", E_UNTERMINATED_STRING, "1:12: unterminated string literal"},
+		{"@a > 1__0", E_INVALID_NUMBER, "1:6: invalid number 1__0: '_' must separate digits"},
+		{"@mask == 0x1G", E_INVALID_NUMBER, "1:10: invalid number 0x1G"},
+		{"@a > 0x1_0000_0000_0000_0000", E_INVALID_NUMBER, "1:6: number 0x1_0000_0000_0000_0000 is out of range"},
+		{"DATE('2025-02-30')", E_INVALID_DATE, `1:6: invalid date "2025-02-30": expected YYYY-MM-DD or an ISO 8601 date-time`},
+		{"@createdAt > NOW() - 1y", E_INVALID_DURATION, "1:22: invalid duration 1y: unit must be one of ms, s, m, h, d or w"},
+		{"NOW(1)", E_ARGUMENT_COUNT, "1:1: NOW expects 0 arguments, got 1"},
+		{"@a IN true", E_ARGUMENT_TYPE, "1:7: IN expects an array or a string, got boolean"},
+		{"SUBSTR(@name, 'a')", E_ARGUMENT_TYPE, "1:15: argument 2 of SUBSTR must be number, got string"},
+		{"FOO(1) > 2", E_UNKNOWN_FUNCTION, "1:1: unknown function FOO"},
+		{"@a > MAX()", E_ARGUMENT_COUNT, "1:6: MAX expects at least 1 argument, got 0"},
+		{"LOG(@a, @b)", E_ARGUMENT_COUNT, "1:1: LOG expects 1 argument, got 2"},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if !p.HasErrors() || len(diagnostics) != 1 {
+			t.Errorf("test[%d] - expected exactly one error for %q, got %v", i, tt.input, p.Errors())
+			continue
+		}
+		if diagnostics[0].Code != tt.expectedCode {
+			t.Errorf("test[%d] - wrong code. got=%s, want=%s", i, diagnostics[0].Code, tt.expectedCode)
+		}
+		if got := diagnostics[0].String(); got != tt.expectedMsg {
+			t.Errorf("test[%d] - wrong message. got=%q, want=%q", i, got, tt.expectedMsg)
+		}
+	}
+}
+
+func TestParseProgramAcceptsCompleteInput(t *testing.T) {
+	p := NewParser(NewLexer("@age > 18 // adults only\n/* and more */"))
+	if expression := p.ParseProgram(); expression == nil || p.HasErrors() {
+		t.Fatalf("ParseProgram() failed. Errors: %v", p.Errors())
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "LOG(@a",
+			expected: []string{"1:7: expected right parenthesis"},
+		},
+		{
+			input: "(@a > ) AND @b IN 5 OR @c == ]",
+			expected: []string{
+				"1:7: unexpected token: )",
+				"1:19: IN expects an array or a string, got number",
+				"1:30: unexpected token: ]",
+			},
+		},
+		{
+			input:    "@a IN [1, 2 + 3] AND @b",
+			expected: []string{},
+		},
+		{
+			input:    "@a IN [1, 2 3, 4] AND @b",
+			expected: []string{"1:13: expected right bracket, got NUMBER"},
+		},
+		{
+			input:    "@a IN [1, (2 3, [4, 5]), 6] OR MAX(@b 1, (2), 3) > 0",
+			expected: []string{"1:14: expected right parenthesis", "1:39: expected right parenthesis"},
+		},
+		{
+			input: "@a IN [1, , 3] AND LOG(@b 2) OR (@c >",
+			expected: []string{
+				"1:11: unexpected token: ,",
+				"1:27: expected right parenthesis",
+				"1:38: unexpected token: EOF",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		if expression := p.ParseProgram(); expression == nil {
+			t.Errorf("test[%d] - ParseProgram() returned nil", i)
+		}
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("test[%d] - wrong number of errors. got=%q, want=%q", i, errors, tt.expected)
+			continue
+		}
+		for j, msg := range tt.expected {
+			if errors[j] != msg {
+				t.Errorf("test[%d] - wrong error[%d]. got=%q, want=%q", i, j, errors[j], msg)
+			}
+		}
+	}
+}
+
+func TestParserLogFunction(t *testing.T) {
+	p := NewParser(NewLexer("log(@a) == 1"))
+	expression := p.ParseProgram()
+	if p.HasErrors() {
+		t.Fatalf("ParseProgram() failed. Errors: %v", p.Errors())
+	}
+
+	jsonLogic, err := Transform(expression)
+	if err != nil {
+		t.Fatalf("Transform() failed: %v", err)
+	}
+
+	result, _ := json.Marshal(jsonLogic)
+	if expected := `{"==":[{"log":[{"var":"a"}]},1]}`; string(result) != expected {
+		t.Errorf("wrong result. got=%s, want=%s", result, expected)
+	}
+}
+
+func TestParserLargeIntegers(t *testing.T) {
+	p := NewParser(NewLexer("9223372036854775808 == @id OR @id IN [123456789012345678901, -99999999999999999999]"))
+	expression := p.ParseProgram()
+	if p.HasErrors() {
+		t.Fatalf("ParseProgram() failed. Errors: %v", p.Errors())
+	}
+
+	jsonLogic, err := Transform(expression)
+	if err != nil {
+		t.Fatalf("Transform() failed: %v", err)
+	}
+
+	// The digits must survive exactly rather than be rounded through a float64
+	result, _ := json.Marshal(jsonLogic)
+	expected := `{"or":[{"==":[9223372036854775808,{"var":"id"}]},{"in":[{"var":"id"},[123456789012345678901,-99999999999999999999]]}]}`
+	if string(result) != expected {
+		t.Errorf("wrong result. got=%s, want=%s", result, expected)
+	}
+}
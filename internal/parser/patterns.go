@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MATCHES and LIKE have no standard JSONLogic operation. They compile to the
+// custom operations {"matches": [value, regex]} and {"like": [value, pattern]},
+// which the JSONLogic engine running the rules must provide; use
+// Registry.SetEmit to target an engine that names them differently. The
+// regular expressions use Go's RE2 syntax.
+//
+// A quoted pattern keeps escapes that strings do not define, so
+// '^.+@corp\.com$' and '50\% off' mean what they say; raw backtick strings
+// avoid the question altogether.
+
+// builtinOperators are the infix operators available to every REL expression
+var builtinOperators = map[string]*Function{
+	"MATCHES": {
+		Name:        "MATCHES",
+		MinArgs:     2,
+		MaxArgs:     2,
+		Params:      []ValueType{TYPE_ANY, TYPE_STRING},
+		Emit:        EmitOperation("matches"),
+		Eval:        evalPattern(compileRegex),
+		literal:     checkPattern(compileRegex),
+		literalCode: E_INVALID_PATTERN,
+		pattern:     true,
+	},
+	"LIKE": {
+		Name:        "LIKE",
+		MinArgs:     2,
+		MaxArgs:     2,
+		Params:      []ValueType{TYPE_ANY, TYPE_STRING},
+		Emit:        EmitOperation("like"),
+		Eval:        evalPattern(compileLike),
+		literal:     checkPattern(compileLike),
+		literalCode: E_INVALID_PATTERN,
+		pattern:     true,
+	},
+}
+
+// patternCacheSize bounds the number of compiled patterns kept by patternCache
+const patternCacheSize = 256
+
+// patternCache holds the most recently used compiled patterns, keyed by the
+// kind of pattern and its source. Patterns can come from the input data, so
+// the least recently used ones are evicted once it is full.
+var patternCache = newPatternLRU(patternCacheSize)
+
+// patternLRU is a least recently used cache of compiled patterns, safe for
+// concurrent use
+type patternLRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *patternEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type patternEntry struct {
+	key string
+	re  *regexp.Regexp
+}
+
+func newPatternLRU(size int) *patternLRU {
+	return &patternLRU{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the pattern cached under key, marking it as recently used
+func (c *patternLRU) get(key string) (*regexp.Regexp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*patternEntry).re, true
+}
+
+// add caches re under key, evicting the least recently used pattern when full
+func (c *patternLRU) add(key string, re *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&patternEntry{key: key, re: re})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*patternEntry).key)
+	}
+}
+
+// len returns the number of cached patterns
+func (c *patternLRU) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// evalPattern returns an implementation of value OP pattern for a pattern compiler
+func evalPattern(compile func(string) (*regexp.Regexp, error)) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		re, err := compile(toString(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(toString(args[0])), nil
+	}
+}
+
+// checkPattern reports whether a literal pattern compiles
+func checkPattern(compile func(string) (*regexp.Regexp, error)) func(string) error {
+	return func(pattern string) error {
+		_, err := compile(pattern)
+		return err
+	}
+}
+
+// compileRegex compiles the regular expression of MATCHES
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	return cachedPattern("matches", pattern, func() (*regexp.Regexp, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+		}
+		return re, nil
+	})
+}
+
+// compileLike translates a LIKE pattern into an anchored regular expression:
+// % matches any run of characters, _ any single character, and a backslash
+// makes the character after it literal
+func compileLike(pattern string) (*regexp.Regexp, error) {
+	return cachedPattern("like", pattern, func() (*regexp.Regexp, error) {
+		var sb strings.Builder
+		sb.WriteString(`(?s)^`)
+
+		escaped := false
+		for _, ch := range pattern {
+			switch {
+			case escaped:
+				sb.WriteString(regexp.QuoteMeta(string(ch)))
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '%':
+				sb.WriteString(`.*`)
+			case ch == '_':
+				sb.WriteString(`.`)
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(ch)))
+			}
+		}
+		if escaped {
+			return nil, fmt.Errorf("invalid LIKE pattern: trailing backslash")
+		}
+
+		sb.WriteString(`$`)
+		return regexp.Compile(sb.String())
+	})
+}
+
+// cachedPattern returns the compiled pattern for kind and source, compiling
+// it on first use
+func cachedPattern(kind, source string, compile func() (*regexp.Regexp, error)) (*regexp.Regexp, error) {
+	key := kind + "\x00" + source
+	if re, ok := patternCache.get(key); ok {
+		return re, nil
+	}
+
+	re, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	patternCache.add(key, re)
+	return re, nil
+}
@@ -0,0 +1,394 @@
+package parser
+
+import "strings"
+
+// Print renders an expression as REL source. Keywords are uppercased and
+// parentheses are only added where the precedence table requires them, so
+// parsing the output yields an equivalent tree.
+func Print(node Expression) string {
+	var sb strings.Builder
+	printExpression(&sb, node)
+	return sb.String()
+}
+
+func printExpression(sb *strings.Builder, node Expression) {
+	switch n := node.(type) {
+	case *BinaryExpression:
+		printBinaryExpression(sb, n)
+	case *UnaryExpression:
+		printUnaryExpression(sb, n)
+	case *Variable:
+		if n.Scope != "" {
+			sb.WriteString(elementSource(n))
+			return
+		}
+		sb.WriteString(variableSource(n.Path))
+	case *Literal:
+		sb.WriteString(literalSource(n))
+	case *ArrayLiteral:
+		sb.WriteString("[")
+		printList(sb, n.Elements)
+		sb.WriteString("]")
+	case *FunctionCall:
+		if n.Implicit {
+			printExpression(sb, n.Arguments[0])
+			return
+		}
+		if n.Infix {
+			printInfixCall(sb, n)
+			return
+		}
+		sb.WriteString(n.Function)
+		sb.WriteString("(")
+		printList(sb, n.Arguments)
+		sb.WriteString(")")
+	case *BetweenExpression:
+		printBetweenExpression(sb, n, false)
+	case *DefaultExpression:
+		printDefaultExpression(sb, n)
+	case *MissingExpression:
+		printMissingExpression(sb, n)
+	case *ConditionalExpression:
+		printConditionalExpression(sb, n)
+	case *QuantifierExpression:
+		printQuantifierExpression(sb, n)
+	case *CollectionExpression:
+		printCollectionExpression(sb, n)
+	case *BadExpression:
+		sb.WriteString(n.Token.Literal)
+	}
+}
+
+// printBinaryExpression prints "left OP right", parenthesising operands that
+// bind looser than the operator. Operators are left-associative, so a right
+// operand at the same level also needs parentheses.
+func printBinaryExpression(sb *strings.Builder, be *BinaryExpression) {
+	precedence := nodePrecedence(be)
+
+	printOperand(sb, be.Left, needsParens(be, be.Left, precedence))
+	sb.WriteString(" ")
+	sb.WriteString(operatorSource(be.Token))
+	sb.WriteString(" ")
+	printOperand(sb, be.Right, needsParens(be, be.Right, precedence+1))
+}
+
+// printBetweenExpression prints "value [NOT] BETWEEN lower AND upper [EXCLUSIVE]".
+// All three operands are parsed as additive expressions.
+func printBetweenExpression(sb *strings.Builder, be *BetweenExpression, negated bool) {
+	printOperand(sb, be.Value, nodePrecedence(be.Value) < FALLBACK)
+	if negated {
+		sb.WriteString(" NOT")
+	}
+	sb.WriteString(" BETWEEN ")
+	printOperand(sb, be.Lower, nodePrecedence(be.Lower) < FALLBACK)
+	sb.WriteString(" AND ")
+	printOperand(sb, be.Upper, nodePrecedence(be.Upper) < FALLBACK)
+	if be.Exclusive {
+		sb.WriteString(" EXCLUSIVE")
+	}
+}
+
+// printInfixCall prints "left NAME right" for a registered operator, whose
+// operands are parsed as additive expressions
+func printInfixCall(sb *strings.Builder, fc *FunctionCall) {
+	left, right := fc.Arguments[0], fc.Arguments[1]
+	printOperand(sb, left, nodePrecedence(left) < FALLBACK)
+	sb.WriteString(" ")
+	sb.WriteString(fc.Function)
+	sb.WriteString(" ")
+	printOperand(sb, right, nodePrecedence(right) < FALLBACK)
+}
+
+// printDefaultExpression prints "a ?? b ?? c", or "DEFAULT(a, b, c)" when
+// written in call form. The operands of ?? are arithmetic expressions.
+func printDefaultExpression(sb *strings.Builder, de *DefaultExpression) {
+	if de.Token.Type == DEFAULT {
+		sb.WriteString("DEFAULT(")
+		printList(sb, de.Operands)
+		sb.WriteString(")")
+		return
+	}
+
+	for i, operand := range de.Operands {
+		if i > 0 {
+			sb.WriteString(" ?? ")
+		}
+		printOperand(sb, operand, nodePrecedence(operand) < SUM)
+	}
+}
+
+// printConditionalExpression prints IF ... THEN ... ELSE ... or
+// CASE WHEN ... THEN ... ELSE ... END. A nested IF is parenthesised unless it
+// is the ELSE of an IF, so an ELSE is never claimed by the wrong conditional.
+func printConditionalExpression(sb *strings.Builder, ce *ConditionalExpression) {
+	isCase := ce.Token.Type == CASE
+	if isCase {
+		sb.WriteString("CASE")
+	}
+
+	for _, branch := range ce.Branches {
+		if isCase {
+			sb.WriteString(" WHEN ")
+		} else {
+			sb.WriteString("IF ")
+		}
+		printOperand(sb, branch.Condition, isIfExpression(branch.Condition))
+		sb.WriteString(" THEN ")
+		printOperand(sb, branch.Result, isIfExpression(branch.Result))
+	}
+
+	if ce.Else != nil {
+		sb.WriteString(" ELSE ")
+		printOperand(sb, ce.Else, isCase && isIfExpression(ce.Else))
+	}
+	if isCase {
+		sb.WriteString(" END")
+	}
+}
+
+// printQuantifierExpression prints ANY collection AS name: body
+func printQuantifierExpression(sb *strings.Builder, qe *QuantifierExpression) {
+	sb.WriteString(string(qe.Token.Type) + " ")
+	// The collection is an operand like those of IN
+	printOperand(sb, qe.Collection, nodePrecedence(qe.Collection) < FALLBACK)
+	sb.WriteString(" AS " + qe.Element + ": ")
+	printExpression(sb, qe.Body)
+}
+
+// printCollectionExpression prints FILTER(collection, x -> body) and its MAP
+// and REDUCE forms
+func printCollectionExpression(sb *strings.Builder, ce *CollectionExpression) {
+	sb.WriteString(string(ce.Token.Type) + "(")
+	printExpression(sb, ce.Collection)
+	sb.WriteString(", ")
+	if len(ce.Params) == 1 {
+		sb.WriteString(ce.Params[0])
+	} else {
+		sb.WriteString("(" + strings.Join(ce.Params, ", ") + ")")
+	}
+	sb.WriteString(" -> ")
+	printExpression(sb, ce.Body)
+	if ce.Initial != nil {
+		sb.WriteString(", ")
+		printExpression(sb, ce.Initial)
+	}
+	sb.WriteString(")")
+}
+
+// isIfExpression reports whether node is an IF conditional, which has no closing keyword
+func isIfExpression(node Expression) bool {
+	ce, ok := node.(*ConditionalExpression)
+	return ok && ce.Token.Type == IF
+}
+
+// printMissingExpression prints presence checks in the form they were written
+func printMissingExpression(sb *strings.Builder, me *MissingExpression) {
+	switch {
+	case me.Need != nil:
+		sb.WriteString("MISSING_SOME(")
+		printExpression(sb, me.Need)
+		sb.WriteString(", [")
+		for i, v := range me.Variables {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			printExpression(sb, v)
+		}
+		sb.WriteString("])")
+	case me.Token.Type == EXISTS:
+		sb.WriteString("EXISTS ")
+		printExpression(sb, me.Variables[0])
+	default:
+		printExpression(sb, me.Variables[0])
+		if me.Negated {
+			sb.WriteString(" IS NOT MISSING")
+		} else {
+			sb.WriteString(" IS MISSING")
+		}
+	}
+}
+
+// printUnaryExpression prints prefix operators and the NOT IN and NOT BETWEEN forms
+func printUnaryExpression(sb *strings.Builder, ue *UnaryExpression) {
+	if in, ok := notInOperand(ue); ok {
+		printOperand(sb, in.Left, needsParens(in, in.Left, COMPARISON))
+		sb.WriteString(" NOT IN ")
+		printExpression(sb, in.Right)
+		return
+	}
+	if between, ok := notBetweenOperand(ue); ok {
+		printBetweenExpression(sb, between, true)
+		return
+	}
+
+	if ue.Operator == "-" {
+		sb.WriteString("-")
+	} else {
+		sb.WriteString("NOT ")
+	}
+	// The operand of a prefix operator is a primary expression
+	printOperand(sb, ue.Right, nodePrecedence(ue.Right) < PREFIX)
+}
+
+func printOperand(sb *strings.Builder, node Expression, parens bool) {
+	if parens {
+		sb.WriteString("(")
+	}
+	printExpression(sb, node)
+	if parens {
+		sb.WriteString(")")
+	}
+}
+
+func printList(sb *strings.Builder, elements []Expression) {
+	for i, elem := range elements {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		printExpression(sb, elem)
+	}
+}
+
+// needsParens decides whether child must be parenthesised when printed as an
+// operand of parent, given the minimum precedence the position accepts.
+func needsParens(parent, child Expression, min OperatorPrecedence) bool {
+	// IN, NOT IN, the BETWEEN forms and registered operators take additive
+	// expressions as operands and cannot be chained with other comparisons, so
+	// comparisons never nest around or inside them without parentheses
+	if isUnchainedComparison(parent) || isUnchainedComparison(child) {
+		childPrecedence := nodePrecedence(child)
+		if childPrecedence == COMPARISON && nodePrecedence(parent) == COMPARISON {
+			return true
+		}
+	}
+	return nodePrecedence(child) < min
+}
+
+// nodePrecedence returns how tightly a node binds when printed
+func nodePrecedence(node Expression) OperatorPrecedence {
+	switch n := node.(type) {
+	case *BinaryExpression:
+		if precedence, ok := precedences[n.Token.Type]; ok {
+			return precedence
+		}
+		return LOWEST
+	case *UnaryExpression:
+		if isUnchainedComparison(n) {
+			return COMPARISON
+		}
+		return PREFIX
+	case *BetweenExpression:
+		return COMPARISON
+	case *DefaultExpression:
+		if n.Token.Type == COALESCE {
+			return FALLBACK
+		}
+		return CALL
+	case *ConditionalExpression:
+		// IF extends as far right as possible; CASE is closed by END
+		if n.Token.Type == IF {
+			return LOWEST
+		}
+		return CALL
+	case *QuantifierExpression:
+		// Like IF, the body extends as far right as possible
+		return LOWEST
+	case *MissingExpression:
+		switch n.Token.Type {
+		case IS:
+			return COMPARISON
+		case EXISTS:
+			return PREFIX
+		default:
+			return CALL
+		}
+	case *FunctionCall:
+		if n.Implicit {
+			return nodePrecedence(n.Arguments[0])
+		}
+		if n.Infix {
+			return COMPARISON
+		}
+		return CALL
+	default:
+		return CALL
+	}
+}
+
+// isUnchainedComparison reports whether node prints as an IN, NOT IN,
+// BETWEEN, NOT BETWEEN, IS MISSING or string comparison, or a registered
+// infix operator
+func isUnchainedComparison(node Expression) bool {
+	switch n := node.(type) {
+	case *BinaryExpression:
+		switch n.Token.Type {
+		case IN, CONTAINS, STARTS_WITH, ENDS_WITH:
+			return true
+		}
+		return false
+	case *UnaryExpression:
+		_, isNotIn := notInOperand(n)
+		_, isNotBetween := notBetweenOperand(n)
+		return isNotIn || isNotBetween
+	case *BetweenExpression:
+		return true
+	case *MissingExpression:
+		return n.Token.Type == IS
+	case *FunctionCall:
+		if n.Implicit {
+			return isUnchainedComparison(n.Arguments[0])
+		}
+		return n.Infix
+	default:
+		return false
+	}
+}
+
+// notInOperand returns the IN expression wrapped by a NOT IN unary node
+func notInOperand(ue *UnaryExpression) (*BinaryExpression, bool) {
+	if ue.Token.Type != BANG {
+		return nil, false
+	}
+	in, ok := ue.Right.(*BinaryExpression)
+	if !ok || in.Token.Type != IN {
+		return nil, false
+	}
+	return in, true
+}
+
+// notBetweenOperand returns the BETWEEN expression wrapped by a NOT BETWEEN unary node
+func notBetweenOperand(ue *UnaryExpression) (*BetweenExpression, bool) {
+	if ue.Token.Type != BANG {
+		return nil, false
+	}
+	between, ok := ue.Right.(*BetweenExpression)
+	return between, ok
+}
+
+// operatorSource returns the canonical spelling of a binary operator
+func operatorSource(tok Token) string {
+	if tok.Type == ASSIGN {
+		return string(EQ)
+	}
+	return string(tok.Type)
+}
+
+// literalSource returns the REL spelling of a literal value
+func literalSource(l *Literal) string {
+	switch l.Token.Type {
+	case STRING:
+		// Raw strings are kept as written, since they are mostly regular expressions
+		if strings.HasPrefix(l.Token.Literal, "`") {
+			return l.Token.Literal
+		}
+		return quoteString(l.Value.(string))
+	case TRUE:
+		return "true"
+	case FALSE:
+		return "false"
+	case NULL:
+		return "null"
+	default:
+		return l.Token.Literal
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Registry holds the functions and infix operators a parser accepts. Every
+// registry starts with the builtin functions; applications add their own
+// domain operations on top, e.g. GEO_WITHIN or HAS_ENTITLEMENT. A registry is
+// used by the parsers it is given to, so different parsers can accept
+// different sets of extensions. Register everything before parsing with the
+// registry; once populated it is safe for concurrent use.
+type Registry struct {
+	functions map[string]*Function
+	operators map[string]*Function
+}
+
+// defaultRegistry is used by parsers created without a registry. It is never
+// handed out, so it only ever holds the builtins.
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates a registry holding the builtin functions and operators
+func NewRegistry() *Registry {
+	r := &Registry{
+		functions: make(map[string]*Function, len(builtinFunctions)),
+		operators: make(map[string]*Function, len(builtinOperators)),
+	}
+	for name, fn := range builtinFunctions {
+		r.functions[name] = fn
+	}
+	for name, fn := range builtinOperators {
+		r.operators[name] = fn
+	}
+	return r
+}
+
+// RegisterFunction makes fn callable as NAME(args...). Names are case
+// insensitive and must not clash with keywords or registered names.
+func (r *Registry) RegisterFunction(fn Function) error {
+	if err := r.validate(&fn); err != nil {
+		return err
+	}
+	r.functions[fn.Name] = &fn
+	return nil
+}
+
+// RegisterOperator makes fn usable as an infix operator, left NAME right,
+// binding like a comparison. The function always receives two arguments.
+func (r *Registry) RegisterOperator(fn Function) error {
+	fn.MinArgs, fn.MaxArgs = 2, 2
+	if err := r.validate(&fn); err != nil {
+		return err
+	}
+	r.operators[fn.Name] = &fn
+	return nil
+}
+
+// validate normalises the name of fn and checks it can be registered
+func (r *Registry) validate(fn *Function) error {
+	fn.Name = strings.ToUpper(fn.Name)
+	if !isIdentifierName(fn.Name) {
+		return fmt.Errorf("invalid function name %q", fn.Name)
+	}
+	if LookupIdentifier(fn.Name) != IDENTIFIER {
+		return fmt.Errorf("%s is a reserved keyword", fn.Name)
+	}
+	if _, ok := r.functions[fn.Name]; ok {
+		return fmt.Errorf("function %s is already registered", fn.Name)
+	}
+	if _, ok := r.operators[fn.Name]; ok {
+		return fmt.Errorf("operator %s is already registered", fn.Name)
+	}
+	if fn.Emit == nil {
+		return fmt.Errorf("function %s has no JSONLogic emitter", fn.Name)
+	}
+	if fn.MaxArgs >= 0 && fn.MaxArgs < fn.MinArgs {
+		return fmt.Errorf("function %s accepts at most %d arguments but requires %d", fn.Name, fn.MaxArgs, fn.MinArgs)
+	}
+	return nil
+}
+
+// SetEmit changes the JSONLogic a registered function or operator compiles
+// to. It is mainly meant for MATCHES and LIKE, whose custom operations an
+// engine may provide under other names, e.g.
+//
+//	registry.SetEmit("MATCHES", EmitOperation("regex_match"))
+func (r *Registry) SetEmit(name string, emit func(args []interface{}) (interface{}, error)) error {
+	name = strings.ToUpper(name)
+	if emit == nil {
+		return fmt.Errorf("function %s has no JSONLogic emitter", name)
+	}
+	for _, table := range []map[string]*Function{r.functions, r.operators} {
+		if fn, ok := table[name]; ok {
+			// Builtins are shared between registries, so change a copy
+			replaced := *fn
+			replaced.Emit = emit
+			table[name] = &replaced
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not registered", name)
+}
+
+// Function returns the function registered under name
+func (r *Registry) Function(name string) (*Function, bool) {
+	fn, ok := r.functions[strings.ToUpper(name)]
+	return fn, ok
+}
+
+// Operator returns the infix operator registered under name
+func (r *Registry) Operator(name string) (*Function, bool) {
+	fn, ok := r.operators[strings.ToUpper(name)]
+	return fn, ok
+}
+
+// isIdentifierName reports whether name lexes as a single identifier
+func isIdentifierName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		if !isNameChar(ch) || (i == 0 && !isLetter(ch)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	registry := NewRegistry()
+	err := registry.RegisterFunction(Function{
+		Name:    "has_entitlement",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []ValueType{TYPE_STRING},
+		Emit:    EmitOperation("has_entitlement"),
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0] == "beta", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction() failed: %v", err)
+	}
+
+	err = registry.RegisterOperator(Function{
+		Name:   "GEO_WITHIN",
+		Params: []ValueType{TYPE_ANY, TYPE_ARRAY},
+		Emit:   EmitOperation("geo_within"),
+	})
+	if err != nil {
+		t.Fatalf("RegisterOperator() failed: %v", err)
+	}
+	return registry
+}
+
+func TestRegistryTransform(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"HAS_ENTITLEMENT('beta')", `{"has_entitlement": ["beta"]}`},
+		{"@location geo_within [1, 2] AND @age > 18", `{"and": [{"geo_within": [{"var": "location"}, [1, 2]]}, {">": [{"var": "age"}, 18]}]}`},
+		{"MAX(@a, 1)", `{"max": [{"var": "a"}, 1]}`},
+	}
+
+	for i, tt := range tests {
+		p := NewParserWithRegistry(NewLexer(tt.input), registry)
+		expression := p.ParseProgram()
+		if p.HasErrors() {
+			t.Errorf("test[%d] - ParseProgram() failed for %q. Errors: %v", i, tt.input, p.Errors())
+			continue
+		}
+
+		jsonLogic, err := Transform(expression)
+		if err != nil {
+			t.Errorf("test[%d] - Transform() failed: %v", i, err)
+			continue
+		}
+
+		result, _ := json.Marshal(jsonLogic)
+		var expected interface{}
+		json.Unmarshal([]byte(tt.expected), &expected)
+		expectedStr, _ := json.Marshal(expected)
+		if string(result) != string(expectedStr) {
+			t.Errorf("test[%d] - wrong result. got=%s, want=%s", i, result, expectedStr)
+		}
+
+		// The canonical form must parse back with the same registry
+		printed := Print(expression)
+		if p := NewParserWithRegistry(NewLexer(printed), registry); p.ParseProgram() == nil || p.HasErrors() {
+			t.Errorf("test[%d] - printed form %q does not parse. Errors: %v", i, printed, p.Errors())
+		}
+	}
+}
+
+func TestRegistryDiagnostics(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	tests := []struct {
+		input        string
+		expectedCode DiagnosticCode
+		expectedMsg  string
+	}{
+		{"HAS_ENTITLEMENT(42)", E_ARGUMENT_TYPE, "1:17: argument 1 of HAS_ENTITLEMENT must be string, got number"},
+		{"HAS_ENTITLEMENT()", E_ARGUMENT_COUNT, "1:1: HAS_ENTITLEMENT expects 1 argument, got 0"},
+		{"@location GEO_WITHIN 'here'", E_ARGUMENT_TYPE, "1:22: argument 2 of GEO_WITHIN must be array, got string"},
+		{"GEO_WITHIN(@a, [1])", E_UNKNOWN_FUNCTION, "1:1: unknown function GEO_WITHIN"},
+	}
+
+	for i, tt := range tests {
+		p := NewParserWithRegistry(NewLexer(tt.input), registry)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("test[%d] - expected exactly one error for %q, got %v", i, tt.input, p.Errors())
+			continue
+		}
+		if diagnostics[0].Code != tt.expectedCode {
+			t.Errorf("test[%d] - wrong code. got=%s, want=%s", i, diagnostics[0].Code, tt.expectedCode)
+		}
+		if got := diagnostics[0].String(); got != tt.expectedMsg {
+			t.Errorf("test[%d] - wrong message. got=%q, want=%q", i, got, tt.expectedMsg)
+		}
+	}
+}
+
+func TestRegistryIsScopedPerParser(t *testing.T) {
+	newTestRegistry(t)
+
+	p := NewParser(NewLexer("HAS_ENTITLEMENT('beta')"))
+	p.ParseProgram()
+	if !p.HasErrors() || p.Diagnostics()[0].Code != E_UNKNOWN_FUNCTION {
+		t.Errorf("expected unknown function error from a parser without the registry, got %v", p.Errors())
+	}
+}
+
+func TestRegistryEvaluate(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	p := NewParserWithRegistry(NewLexer("HAS_ENTITLEMENT(@plan) AND @location GEO_WITHIN [1, 2]"), registry)
+	expression := p.ParseProgram()
+	if p.HasErrors() {
+		t.Fatalf("ParseProgram() failed. Errors: %v", p.Errors())
+	}
+
+	result, err := Evaluate(expression, map[string]interface{}{"plan": "free"})
+	if err != nil || result != false {
+		t.Errorf("wrong result. got=%v, %v, want=false", result, err)
+	}
+
+	// GEO_WITHIN has no Go implementation
+	if _, err := Evaluate(expression, map[string]interface{}{"plan": "beta"}); err == nil {
+		t.Errorf("expected an error evaluating an operator without an implementation")
+	}
+}
+
+func TestRegistrySetEmit(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.SetEmit("matches", EmitOperation("regex_match")); err != nil {
+		t.Fatalf("SetEmit() failed: %v", err)
+	}
+
+	for input, expected := range map[*Registry]string{
+		registry:      `{"regex_match":[{"var":"a"},"x"]}`,
+		NewRegistry(): `{"matches":[{"var":"a"},"x"]}`,
+	} {
+		p := NewParserWithRegistry(NewLexer("@a MATCHES 'x'"), input)
+		jsonLogic, err := Transform(p.ParseProgram())
+		if err != nil {
+			t.Fatalf("Transform() failed: %v", err)
+		}
+		if got, _ := json.Marshal(jsonLogic); string(got) != expected {
+			t.Errorf("wrong result. got=%s, want=%s", got, expected)
+		}
+	}
+
+	if err := registry.SetEmit("UNKNOWN", EmitOperation("x")); err == nil {
+		t.Errorf("expected SetEmit() to fail for an unregistered name")
+	}
+}
+
+func TestRegistryRejectsInvalidRegistrations(t *testing.T) {
+	emit := EmitOperation("op")
+
+	tests := []struct {
+		name string
+		fn   Function
+	}{
+		{"keyword", Function{Name: "between", Emit: emit}},
+		{"builtin", Function{Name: "Max", Emit: emit}},
+		{"not an identifier", Function{Name: "GEO-WITHIN", Emit: emit}},
+		{"no emitter", Function{Name: "CUSTOM"}},
+		{"bad arity", Function{Name: "CUSTOM", MinArgs: 2, MaxArgs: 1, Emit: emit}},
+	}
+
+	for _, tt := range tests {
+		if err := NewRegistry().RegisterFunction(tt.fn); err == nil {
+			t.Errorf("%s: expected RegisterFunction() to fail", tt.name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// String literals are quoted with ' or " and support the escapes \' \" \\ \/
+// \b \f \n \r \t, \uXXXX (UTF-16 surrogate pairs included) and \u{X...}.
+// Raw strings are quoted with backticks, have no escapes and can span lines,
+// which suits regular expressions: @email MATCHES `^\w+@corp\.com$`. Any
+// other escape is an error, except in the pattern of MATCHES or LIKE, where
+// it is kept as written.
+
+// simpleEscapes maps the character after a backslash to the text it stands for
+var simpleEscapes = map[byte]string{
+	'\'': "'",
+	'"':  `"`,
+	'\\': `\`,
+	'/':  "/",
+	'b':  "\b",
+	'f':  "\f",
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+}
+
+// decodeEscape decodes the escape sequence at the start of s, which follows a
+// backslash. It returns the decoded text and the number of bytes of s the
+// sequence covers, which is also how far to skip when it is invalid.
+func decodeEscape(s string) (string, int, error) {
+	if s == "" {
+		return "", 0, nil
+	}
+	if text, ok := simpleEscapes[s[0]]; ok {
+		return text, 1, nil
+	}
+	if s[0] == 'u' {
+		return decodeUnicodeEscape(s)
+	}
+
+	_, size := utf8.DecodeRuneInString(s)
+	return "", size, fmt.Errorf(`invalid escape sequence \%s`, s[:size])
+}
+
+// decodeUnicodeEscape decodes \uXXXX, a surrogate pair \uXXXX\uXXXX, or \u{X...}
+func decodeUnicodeEscape(s string) (string, int, error) {
+	if strings.HasPrefix(s, "u{") {
+		end := strings.IndexByte(s, '}')
+		digits := s[2:max(end, 2)]
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if end < 0 || len(digits) > 6 || err != nil {
+			return "", hexPrefix(s, 2), fmt.Errorf(`invalid Unicode escape \%s`, s[:hexPrefix(s, 2)])
+		}
+		if r := rune(code); utf8.ValidRune(r) {
+			return string(r), end + 1, nil
+		}
+		return "", end + 1, fmt.Errorf(`invalid Unicode escape \%s: not a Unicode character`, s[:end+1])
+	}
+
+	r, ok := hex4(s[1:])
+	if !ok {
+		return "", hexPrefix(s, 1), fmt.Errorf(`invalid Unicode escape \%s: expected 4 hex digits`, s[:hexPrefix(s, 1)])
+	}
+	if r < 0xD800 || r > 0xDFFF {
+		return string(r), 5, nil
+	}
+
+	// A high surrogate must be followed by a low one
+	if r < 0xDC00 && strings.HasPrefix(s[5:], `\u`) {
+		if low, ok := hex4(s[7:]); ok && low >= 0xDC00 && low <= 0xDFFF {
+			return string(0x10000 + (r-0xD800)<<10 + (low - 0xDC00)), 11, nil
+		}
+	}
+	return "", 5, fmt.Errorf(`invalid Unicode escape \%s: unpaired surrogate`, s[:5])
+}
+
+// hex4 parses the four hex digits at the start of s
+func hex4(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	for i := 0; i < 4; i++ {
+		if !isHexDigit(rune(s[i])) {
+			return 0, false
+		}
+	}
+	code, err := strconv.ParseUint(s[:4], 16, 32)
+	return rune(code), err == nil
+}
+
+// hexPrefix returns the length of s up to the end of the hex digits after its
+// first skip bytes, covering a malformed Unicode escape
+func hexPrefix(s string, skip int) int {
+	end := skip
+	for end < len(s) && isHexDigit(rune(s[end])) {
+		end++
+	}
+	return end
+}
+
+// unquote returns the value of a string literal. Invalid escapes, which the
+// lexer reports, are kept as written.
+func unquote(literal string) string {
+	if len(literal) < 2 {
+		return literal
+	}
+	body := literal[1 : len(literal)-1]
+	if literal[0] == '`' || !strings.Contains(body, `\`) {
+		return body
+	}
+
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(body, '\\')
+		if i < 0 {
+			sb.WriteString(body)
+			return sb.String()
+		}
+		sb.WriteString(body[:i])
+
+		text, size, err := decodeEscape(body[i+1:])
+		if err != nil || size == 0 {
+			text = body[i : i+1+size]
+		}
+		sb.WriteString(text)
+		body = body[i+1+size:]
+	}
+}
+
+// quoteString returns the REL source of a string value: single quotes,
+// switching to double quotes when the value contains a single quote, with
+// the quote, backslashes and control characters escaped
+func quoteString(value string) string {
+	quote := '\''
+	if strings.ContainsRune(value, '\'') {
+		quote = '"'
+	}
+
+	var sb strings.Builder
+	sb.WriteRune(quote)
+	for _, ch := range value {
+		switch ch {
+		case quote, '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(ch)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if ch < 0x20 || ch == 0x7f {
+				fmt.Fprintf(&sb, `\u%04x`, ch)
+			} else {
+				sb.WriteRune(ch)
+			}
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
+
+// utf16Length returns the length of s in UTF-16 code units, the unit
+// JavaScript and so JSONLogic's substr count in
+func utf16Length(s string) int {
+	n := 0
+	for _, ch := range s {
+		n += utf16.RuneLen(ch)
+	}
+	return n
+}
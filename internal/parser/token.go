@@ -1,12 +1,40 @@
 package parser
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type TokenType string
 
+// Position is a location in the source text. Offset is a zero-based byte
+// offset, Line and Column are one-based. Columns count Unicode characters.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the half-open range of source text [Start, End) covered by a token or node
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Start   Position // position of the first character of the token
+	End     Position // position just past the last character of the token
+}
+
+// Span returns the source range covered by the token
+func (t Token) Span() Span {
+	return Span{Start: t.Start, End: t.End}
 }
 
 func NewToken(tokenType TokenType, literal string) Token {
@@ -25,12 +53,14 @@ const (
 	VARIABLE   TokenType = "VARIABLE"
 	IDENTIFIER TokenType = "IDENTIFIER"
 	NUMBER     TokenType = "NUMBER"
+	DURATION   TokenType = "DURATION"
 	STRING     TokenType = "STRING"
 
 	// Delimiters
 	ASSIGN    TokenType = "="
 	SEMICOLON TokenType = ";"
 	COMMA     TokenType = ","
+	COLON     TokenType = ":"
 
 	// Brackets
 	LPAREN   TokenType = "("
@@ -50,6 +80,15 @@ const (
 	LT         TokenType = "<"
 	GTE        TokenType = ">="
 	LTE        TokenType = "<="
+	COALESCE   TokenType = "??"
+	ARROW      TokenType = "->"
+
+	// Arithmetic Operators
+	PLUS     TokenType = "+"
+	MINUS    TokenType = "-"
+	ASTERISK TokenType = "*"
+	SLASH    TokenType = "/"
+	PERCENT  TokenType = "%"
 
 	// Keywords
 	AND TokenType = "AND"
@@ -57,6 +96,40 @@ const (
 	IN  TokenType = "IN"
 	NOT TokenType = "NOT"
 	LOG TokenType = "LOG"
+
+	BETWEEN   TokenType = "BETWEEN"
+	EXCLUSIVE TokenType = "EXCLUSIVE"
+	DEFAULT   TokenType = "DEFAULT"
+
+	EXISTS       TokenType = "EXISTS"
+	IS           TokenType = "IS"
+	MISSING      TokenType = "MISSING"
+	MISSING_SOME TokenType = "MISSING_SOME"
+
+	IF   TokenType = "IF"
+	THEN TokenType = "THEN"
+	ELSE TokenType = "ELSE"
+	CASE TokenType = "CASE"
+	WHEN TokenType = "WHEN"
+	END  TokenType = "END"
+
+	ANY  TokenType = "ANY"
+	ALL  TokenType = "ALL"
+	NONE TokenType = "NONE"
+	AS   TokenType = "AS"
+
+	CONTAINS    TokenType = "CONTAINS"
+	STARTS_WITH TokenType = "STARTS_WITH"
+	ENDS_WITH   TokenType = "ENDS_WITH"
+
+	FILTER TokenType = "FILTER"
+	MAP    TokenType = "MAP"
+	REDUCE TokenType = "REDUCE"
+
+	// Literal Keywords
+	TRUE  TokenType = "TRUE"
+	FALSE TokenType = "FALSE"
+	NULL  TokenType = "NULL"
 )
 
 var keywords = map[string]TokenType{
@@ -65,6 +138,39 @@ var keywords = map[string]TokenType{
 	"IN":  IN,
 	"NOT": BANG,
 	"LOG": LOG,
+
+	"BETWEEN":   BETWEEN,
+	"EXCLUSIVE": EXCLUSIVE,
+	"DEFAULT":   DEFAULT,
+
+	"EXISTS":       EXISTS,
+	"IS":           IS,
+	"MISSING":      MISSING,
+	"MISSING_SOME": MISSING_SOME,
+
+	"IF":   IF,
+	"THEN": THEN,
+	"ELSE": ELSE,
+	"CASE": CASE,
+	"WHEN": WHEN,
+	"END":  END,
+
+	"ANY":  ANY,
+	"ALL":  ALL,
+	"NONE": NONE,
+	"AS":   AS,
+
+	"CONTAINS":    CONTAINS,
+	"STARTS_WITH": STARTS_WITH,
+	"ENDS_WITH":   ENDS_WITH,
+
+	"FILTER": FILTER,
+	"MAP":    MAP,
+	"REDUCE": REDUCE,
+
+	"TRUE":  TRUE,
+	"FALSE": FALSE,
+	"NULL":  NULL,
 }
 
 func LookupIdentifier(ident string) TokenType {
@@ -74,3 +180,15 @@ func LookupIdentifier(ident string) TokenType {
 	}
 	return IDENTIFIER
 }
+
+// describeToken renders a token for use in error messages
+func describeToken(tok Token) string {
+	switch {
+	case tok.Type == EOF:
+		return "end of input"
+	case string(tok.Type) == tok.Literal:
+		return fmt.Sprintf("'%s'", tok.Literal)
+	default:
+		return fmt.Sprintf("%s '%s'", tok.Type, tok.Literal)
+	}
+}
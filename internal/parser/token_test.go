@@ -16,6 +16,9 @@ func TestLookupKeyword(t *testing.T) {
 		{"IN", parser.IN},
 		{"NOT", parser.BANG},
 		{"LOG", parser.LOG},
+		{"true", parser.TRUE},
+		{"FALSE", parser.FALSE},
+		{"null", parser.NULL},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// variablePath splits the source of a variable, e.g. @items[0]["unit-price"],
+// into its path segments: items, 0, unit-price
+func variablePath(literal string) []string {
+	var path []string
+	rest := strings.TrimPrefix(literal, "@")
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+		case '[':
+			// A quoted key may itself contain ']', so look for it after the closing quote
+			from := 0
+			if len(rest) > 1 && (rest[1] == '\'' || rest[1] == '"') {
+				if closing := strings.IndexByte(rest[2:], rest[1]); closing >= 0 {
+					from = closing + 2
+				}
+			}
+			end := strings.IndexByte(rest[from:], ']')
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += from
+			}
+			segment := rest[1:end]
+			if len(segment) >= 2 && (segment[0] == '\'' || segment[0] == '"') && segment[len(segment)-1] == segment[0] {
+				segment = segment[1 : len(segment)-1]
+			}
+			path = append(path, segment)
+			rest = rest[min(end+1, len(rest)):]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		}
+	}
+	return path
+}
+
+// variableSource renders a variable path as canonical REL: names as
+// .segments, numbers as [indices] and anything else as ['quoted keys']
+func variableSource(path []string) string {
+	return "@" + path[0] + segmentsSource(path[1:])
+}
+
+// elementSource renders a variable that starts from a scoped name, e.g. item.price
+func elementSource(v *Variable) string {
+	path := v.Path
+	if v.Key != "" {
+		path = path[1:]
+	}
+	return v.Scope + segmentsSource(path)
+}
+
+// segmentsSource renders the segments following the start of a path
+func segmentsSource(segments []string) string {
+	var sb strings.Builder
+	for _, segment := range segments {
+		switch {
+		case isIndex(segment):
+			sb.WriteString("[" + segment + "]")
+		case isPathName(segment):
+			sb.WriteString("." + segment)
+		default:
+			sb.WriteString("[" + quoteKey(segment) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// quoteKey quotes a bracketed key of a path. Keys are read literally, without
+// escapes, so the quote is chosen to not appear in the key.
+func quoteKey(segment string) string {
+	if strings.Contains(segment, "'") {
+		return `"` + segment + `"`
+	}
+	return "'" + segment + "'"
+}
+
+// isPathName reports whether segment can be written after '@' or '.'
+func isPathName(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, ch := range segment {
+		if !isNameChar(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndex reports whether segment is an array index
+func isIndex(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, ch := range segment {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupVariable follows path through nested maps and arrays, returning nil
+// when any part of it is absent, like JSONLogic's var
+func lookupVariable(path []string, data interface{}) interface{} {
+	value, _ := lookupPath(path, data)
+	return value
+}
+
+// lookupPath follows path through nested maps and arrays and reports whether
+// the value exists. A value that is present but null is found.
+func lookupPath(path []string, data interface{}) (interface{}, bool) {
+	current := data
+	for _, segment := range path {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		default:
+			items, ok := asArray(value)
+			if !ok {
+				return nil, false
+			}
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(items) {
+				return nil, false
+			}
+			current = items[index]
+		}
+	}
+	return current, true
+}
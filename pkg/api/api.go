@@ -0,0 +1,278 @@
+// Package api is the supported Go interface to REL (Rule Expression Language).
+//
+// It parses REL source into an AST, compiles it to JSONLogic and evaluates it
+// against data:
+//
+//	rule, err := api.Compile("@age > 18 AND @country == 'IN'")
+//	if err != nil {
+//		// err is a *ParseError or *CompileError
+//	}
+//	logic := rule.JSONLogic()
+//	ok, err := rule.Evaluate(map[string]interface{}{"age": 21, "country": "IN"})
+//
+// The package-level functions use the default Options; set fields on an
+// Options value and call its methods to change behaviour.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dhruvsaxena1998/rel/internal/parser"
+)
+
+// Types re-exported from the parser so callers can inspect ASTs and diagnostics
+type (
+	Expression     = parser.Expression
+	Diagnostic     = parser.Diagnostic
+	DiagnosticCode = parser.DiagnosticCode
+	Severity       = parser.Severity
+	Position       = parser.Position
+	Span           = parser.Span
+)
+
+// Types for extending REL with application-specific functions and operators
+type (
+	Registry  = parser.Registry
+	Function  = parser.Function
+	ValueType = parser.ValueType
+)
+
+// Argument types for Function.Params
+const (
+	TYPE_ANY     = parser.TYPE_ANY
+	TYPE_NUMBER  = parser.TYPE_NUMBER
+	TYPE_STRING  = parser.TYPE_STRING
+	TYPE_BOOLEAN = parser.TYPE_BOOLEAN
+	TYPE_ARRAY   = parser.TYPE_ARRAY
+)
+
+// NewRegistry creates a registry holding the builtin functions and
+// operators. Register custom functions and operators on it and set it as
+// Options.Registry:
+//
+//	registry := api.NewRegistry()
+//	registry.RegisterFunction(api.Function{
+//		Name:    "HAS_ENTITLEMENT",
+//		MinArgs: 1,
+//		MaxArgs: 1,
+//		Params:  []api.ValueType{api.TYPE_STRING},
+//		Emit:    api.EmitOperation("has_entitlement"),
+//	})
+//	rule, err := api.Options{Registry: registry}.Compile("HAS_ENTITLEMENT('beta')")
+//
+// MATCHES and LIKE compile to the custom operations "matches" and "like";
+// use SetEmit when the target JSONLogic engine names them differently.
+func NewRegistry() *Registry {
+	return parser.NewRegistry()
+}
+
+// EmitOperation returns an emitter producing {operator: [args...]}, for
+// functions that map onto a custom operation of the JSONLogic engine
+func EmitOperation(operator string) func(args []interface{}) (interface{}, error) {
+	return parser.EmitOperation(operator)
+}
+
+// Options configures parsing, compilation and evaluation. The zero value is
+// ready to use.
+type Options struct {
+	// MaxLength rejects sources longer than this many bytes; 0 means no limit
+	MaxLength int
+
+	// Registry supplies custom functions and operators; nil means only the builtins
+	Registry *Registry
+
+	// Clock returns the time NOW() evaluates to; nil means time.Now
+	Clock func() time.Time
+
+	// UseNumber makes the compiled JSONLogic hold numbers as json.Number
+	// instead of int64 and float64
+	UseNumber bool
+}
+
+// Rule is a compiled REL expression. It is immutable and safe for concurrent use.
+type Rule struct {
+	source     string
+	expression Expression
+	jsonLogic  interface{}
+	options    Options
+}
+
+// Parse parses source into an AST using the default options
+func Parse(source string) (Expression, error) {
+	return Options{}.Parse(source)
+}
+
+// Format rewrites source in canonical REL style using the default options
+func Format(source string) (string, error) {
+	return Options{}.Format(source)
+}
+
+// Compile parses source and compiles it to JSONLogic using the default options
+func Compile(source string) (*Rule, error) {
+	return Options{}.Compile(source)
+}
+
+// MustCompile is like Compile but panics if the source cannot be compiled.
+// It simplifies initialisation of package-level rules.
+func MustCompile(source string) *Rule {
+	rule, err := Compile(source)
+	if err != nil {
+		panic("api: Compile(" + quote(source) + "): " + err.Error())
+	}
+	return rule
+}
+
+// ToJSONLogic translates source straight to a JSONLogic value using the default options
+func ToJSONLogic(source string) (interface{}, error) {
+	return Options{}.ToJSONLogic(source)
+}
+
+// Parse parses source into an AST. The whole input must form one expression;
+// any problems are returned together as a *ParseError.
+func (o Options) Parse(source string) (Expression, error) {
+	expression, _, err := o.parse(source)
+	return expression, err
+}
+
+// parse parses source and also returns the comments found in it
+func (o Options) parse(source string) (Expression, []parser.Comment, error) {
+	if o.MaxLength > 0 && len(source) > o.MaxLength {
+		return nil, nil, &ParseError{Source: source, Diagnostics: []Diagnostic{tooLongDiagnostic(source, o.MaxLength)}}
+	}
+
+	p := parser.NewParserWithRegistry(parser.NewLexer(source), o.Registry)
+	expression := p.ParseProgram()
+	if expression == nil || p.HasErrors() {
+		return nil, nil, &ParseError{Source: source, Diagnostics: p.Diagnostics()}
+	}
+	return expression, p.Comments(), nil
+}
+
+// Format rewrites source in canonical REL style: uppercase keywords,
+// consistent spacing, minimal parentheses and long AND/OR chains wrapped one
+// operand per line. Comments are preserved. The result ends with a newline.
+func (o Options) Format(source string) (string, error) {
+	expression, comments, err := o.parse(source)
+	if err != nil {
+		return "", err
+	}
+	return parser.Format(expression, comments) + "\n", nil
+}
+
+// Compile parses source and compiles it to JSONLogic
+func (o Options) Compile(source string) (*Rule, error) {
+	expression, err := o.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonLogic, err := parser.Transform(expression)
+	if err != nil {
+		return nil, &CompileError{Source: source, Err: err}
+	}
+	if o.UseNumber {
+		jsonLogic = parser.UseNumber(jsonLogic)
+	}
+
+	return &Rule{
+		source:     source,
+		expression: expression,
+		jsonLogic:  jsonLogic,
+		options:    o,
+	}, nil
+}
+
+// ToJSONLogic translates source straight to a JSONLogic value
+func (o Options) ToJSONLogic(source string) (interface{}, error) {
+	rule, err := o.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return rule.jsonLogic, nil
+}
+
+// Source returns the REL source the rule was compiled from
+func (r *Rule) Source() string {
+	return r.source
+}
+
+// String returns the REL source the rule was compiled from
+func (r *Rule) String() string {
+	return r.source
+}
+
+// Expression returns the rule's AST
+func (r *Rule) Expression() Expression {
+	return r.expression
+}
+
+// JSONLogic returns the rule compiled to JSONLogic. The value must not be modified.
+func (r *Rule) JSONLogic() interface{} {
+	return r.jsonLogic
+}
+
+// MarshalJSON encodes the rule as its JSONLogic form. Operators such as ">"
+// are written as-is rather than HTML-escaped.
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.jsonLogic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Evaluate runs the rule against data and returns the result
+func (r *Rule) Evaluate(data map[string]interface{}) (interface{}, error) {
+	result, err := r.options.evaluator().Evaluate(r.expression, data)
+	if err != nil {
+		return nil, &EvalError{Source: r.source, Err: err}
+	}
+	return result, nil
+}
+
+// Match runs the rule against data and reports whether the result is truthy
+// under JSONLogic rules
+func (r *Rule) Match(data map[string]interface{}) (bool, error) {
+	result, err := r.Evaluate(data)
+	if err != nil {
+		return false, err
+	}
+	return parser.Truthy(result), nil
+}
+
+// evaluator builds the parser evaluator configured by the options
+func (o Options) evaluator() *parser.Evaluator {
+	evaluator := parser.NewEvaluator()
+	evaluator.Clock = o.Clock
+	return evaluator
+}
+
+// Decompile converts a JSONLogic value (as produced by encoding/json) into REL source
+func Decompile(jsonLogic interface{}) (string, error) {
+	expression, err := parser.Decompile(jsonLogic)
+	if err != nil {
+		return "", &DecompileError{Err: err}
+	}
+	return parser.Format(expression, nil), nil
+}
+
+// DecompileJSON converts an encoded JSONLogic document into REL source
+func DecompileJSON(data []byte) (string, error) {
+	var jsonLogic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&jsonLogic); err != nil {
+		return "", &DecompileError{Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+	// Like REL source, the document must hold exactly one rule
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		return "", &DecompileError{Err: fmt.Errorf("invalid JSON: unexpected data after the JSONLogic value at offset %d", dec.InputOffset())}
+	}
+	return Decompile(jsonLogic)
+}
@@ -0,0 +1,198 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhruvsaxena1998/rel/pkg/api"
+)
+
+func TestCompile(t *testing.T) {
+	rule, err := api.Compile("@age > 18 AND @country == 'IN'")
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+
+	result, err := rule.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() failed: %v", err)
+	}
+	expected := `{"and":[{">":[{"var":"age"},18]},{"==":[{"var":"country"},"IN"]}]}`
+	if string(result) != expected {
+		t.Errorf("wrong JSONLogic. got=%s, want=%s", result, expected)
+	}
+
+	tests := []struct {
+		data     map[string]interface{}
+		expected bool
+	}{
+		{map[string]interface{}{"age": 21, "country": "IN"}, true},
+		{map[string]interface{}{"age": 16, "country": "IN"}, false},
+		{map[string]interface{}{"country": "IN"}, false},
+	}
+
+	for i, tt := range tests {
+		matched, err := rule.Match(tt.data)
+		if err != nil {
+			t.Errorf("test[%d] - Match() failed: %v", i, err)
+			continue
+		}
+		if matched != tt.expected {
+			t.Errorf("test[%d] - wrong result. got=%v, want=%v", i, matched, tt.expected)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := api.Parse("(@a > ) AND @b ==")
+
+	var parseErr *api.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *api.ParseError, got %T (%v)", err, err)
+	}
+	if len(parseErr.Diagnostics) != 2 {
+		t.Errorf("expected 2 diagnostics, got %v", parseErr.Diagnostics)
+	}
+}
+
+func TestOptionsMaxLength(t *testing.T) {
+	opts := api.Options{MaxLength: 8}
+
+	if _, err := opts.Compile("@a > 1"); err != nil {
+		t.Errorf("Compile() failed for short input: %v", err)
+	}
+
+	_, err := opts.Compile("@age > 18")
+	var parseErr *api.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Diagnostics[0].Code != api.E_INPUT_TOO_LONG {
+		t.Errorf("expected %s, got %v", api.E_INPUT_TOO_LONG, err)
+	}
+
+	// The span ends after the last character, counted like other columns
+	_, err = opts.Compile("@größe > 1\n@名前 == 'x'")
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *api.ParseError, got %v", err)
+	}
+	if end := parseErr.Diagnostics[0].Span.End; end != (api.Position{Offset: 27, Line: 2, Column: 11}) {
+		t.Errorf("wrong end of span. got=%+v", end)
+	}
+}
+
+func TestOptionsRegistry(t *testing.T) {
+	registry := api.NewRegistry()
+	err := registry.RegisterFunction(api.Function{
+		Name:    "HAS_ENTITLEMENT",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []api.ValueType{api.TYPE_STRING},
+		Emit:    api.EmitOperation("has_entitlement"),
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0] == "beta", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction() failed: %v", err)
+	}
+
+	rule, err := api.Options{Registry: registry}.Compile("HAS_ENTITLEMENT('beta')")
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	if got, _ := rule.MarshalJSON(); string(got) != `{"has_entitlement":["beta"]}` {
+		t.Errorf("wrong JSONLogic. got=%s", got)
+	}
+	if ok, err := rule.Match(nil); !ok || err != nil {
+		t.Errorf("Match() = %v, %v, want true", ok, err)
+	}
+
+	// Other options do not see the registry
+	if _, err := api.Compile("HAS_ENTITLEMENT('beta')"); err == nil {
+		t.Errorf("expected Compile() without the registry to fail")
+	}
+}
+
+func TestOptionsClock(t *testing.T) {
+	opts := api.Options{Clock: func() time.Time {
+		return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	}}
+
+	rule, err := opts.Compile("@createdAt > NOW() - 30d")
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	if got, _ := rule.MarshalJSON(); string(got) != `{">":[{"date":[{"var":"createdAt"}]},{"-":[{"now":[]},2592000000]}]}` {
+		t.Errorf("wrong JSONLogic. got=%s", got)
+	}
+
+	for createdAt, expected := range map[string]bool{"2025-02-15": true, "2025-01-15T08:00:00Z": false} {
+		if ok, err := rule.Match(map[string]interface{}{"createdAt": createdAt}); ok != expected || err != nil {
+			t.Errorf("Match(%s) = %v, %v, want %v", createdAt, ok, err, expected)
+		}
+	}
+}
+
+func TestOptionsUseNumber(t *testing.T) {
+	source := "@accountId == 9007199254740993 OR @score > 1.5"
+
+	rule := api.MustCompile(source)
+	if got, _ := rule.MarshalJSON(); string(got) != `{"or":[{"==":[{"var":"accountId"},9007199254740993]},{">":[{"var":"score"},1.5]}]}` {
+		t.Errorf("wrong JSONLogic. got=%s", got)
+	}
+	if ok, _ := rule.Match(map[string]interface{}{"accountId": json.Number("9007199254740992")}); ok {
+		t.Errorf("expected neighbouring account IDs to differ")
+	}
+
+	rule, err := api.Options{UseNumber: true}.Compile(source)
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	// %#v quotes a json.Number but not an int64 or float64
+	if got := fmt.Sprintf("%#v", rule.JSONLogic()); !strings.Contains(got, `"9007199254740993"`) || !strings.Contains(got, `"1.5"`) {
+		t.Errorf("expected json.Number values, got %s", got)
+	}
+}
+
+func TestMustCompilePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MustCompile to panic")
+		}
+	}()
+	api.MustCompile("@a >")
+}
+
+func TestDecompileJSON(t *testing.T) {
+	rel, err := api.DecompileJSON([]byte(`{"or": [{">=": [{"var": "score"}, 75]}, {"==": [{"var": "vip"}, true]}]}`))
+	if err != nil {
+		t.Fatalf("DecompileJSON() failed: %v", err)
+	}
+	if expected := "@score >= 75 OR @vip == true"; rel != expected {
+		t.Errorf("wrong REL. got=%q, want=%q", rel, expected)
+	}
+
+	if rel, err := api.DecompileJSON([]byte("{\"var\": \"a\"}\n")); err != nil || rel != "@a" {
+		t.Errorf("expected trailing whitespace to be accepted. got=%q, err=%v", rel, err)
+	}
+
+	for _, input := range []string{`{"some": []}`, `{"var":"a"} trailing`, `{"var":"a"} {"var":"b"}`, `1 2`} {
+		_, err = api.DecompileJSON([]byte(input))
+		var decompileErr *api.DecompileError
+		if !errors.As(err, &decompileErr) {
+			t.Errorf("expected *api.DecompileError for %q, got %T (%v)", input, err, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	formatted, err := api.Format("@age>18 and  not @banned // adults\n")
+	if err != nil {
+		t.Fatalf("Format() failed: %v", err)
+	}
+	if expected := "@age > 18 AND NOT @banned // adults\n"; formatted != expected {
+		t.Errorf("wrong format. got=%q, want=%q", formatted, expected)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhruvsaxena1998/rel/internal/parser"
+)
+
+// E_INPUT_TOO_LONG is reported when a source exceeds Options.MaxLength
+const E_INPUT_TOO_LONG DiagnosticCode = "E_INPUT_TOO_LONG"
+
+// ParseError is returned when REL source is not a valid expression. It carries
+// every diagnostic the parser reported.
+type ParseError struct {
+	Source      string
+	Diagnostics []Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Diagnostics) == 0 {
+		return "rel: invalid expression"
+	}
+
+	messages := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		messages[i] = d.String()
+	}
+	return "rel: " + strings.Join(messages, "; ")
+}
+
+// CompileError is returned when a parsed expression cannot be translated to JSONLogic
+type CompileError struct {
+	Source string
+	Err    error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("rel: compile: %v", e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// EvalError is returned when evaluating a rule against data fails
+type EvalError struct {
+	Source string
+	Err    error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("rel: evaluate: %v", e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// tooLongDiagnostic reports a source that exceeds the configured maximum length
+func tooLongDiagnostic(source string, max int) Diagnostic {
+	end := parser.Position{Offset: len(source), Line: 1 + strings.Count(source, "\n")}
+	// Columns count characters, like those of every other diagnostic
+	end.Column = 1 + utf8.RuneCountInString(source[strings.LastIndex(source, "\n")+1:])
+	return Diagnostic{
+		Severity: parser.SeverityError,
+		Code:     E_INPUT_TOO_LONG,
+		Message:  fmt.Sprintf("expression is %d bytes long, the maximum is %d", len(source), max),
+		Span:     Span{Start: Position{Offset: 0, Line: 1, Column: 1}, End: end},
+	}
+}
+
+// quote shortens long sources for panic messages
+func quote(source string) string {
+	const limit = 64
+	if len(source) > limit {
+		source = source[:limit] + "..."
+	}
+	return strconv.Quote(source)
+}
+
+// DecompileError is returned when JSONLogic cannot be expressed in REL
+type DecompileError struct {
+	Err error
+}
+
+func (e *DecompileError) Error() string {
+	return fmt.Sprintf("rel: decompile: %v", e.Err)
+}
+
+func (e *DecompileError) Unwrap() error {
+	return e.Err
+}